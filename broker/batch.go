@@ -0,0 +1,220 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// MaxBatchSize is the SQS limit of entries per batch call.
+const MaxBatchSize = 10
+
+// batchAttempts is how many times an entry that failed on the server side is
+// sent again before it is reported as failed.
+const batchAttempts = 3
+
+type (
+	BatchMessage struct {
+		// Id identifies the entry in the BatchResult, it is not sent to SQS.
+		Id         string
+		Body       []byte
+		Attributes map[string]types.MessageAttributeValue
+		Group      *string
+		DupId      *string
+	}
+
+	BatchFailure struct {
+		Id          string
+		Code        string
+		Message     string
+		SenderFault bool
+	}
+
+	// BatchResult lists the ids that made it and the ones that did not after
+	// every retry. For deletes the ids are the receipt handles.
+	BatchResult struct {
+		Successful []string
+		Failed     []BatchFailure
+	}
+
+	// BatchCall sends one chunk of at most MaxBatchSize entries, addressed by
+	// their position in the chunk, and returns the failures by position.
+	BatchCall func(ctx context.Context, chunk []int) (map[int]BatchFailure, error)
+)
+
+func (r BatchResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	f := r.Failed[0]
+	return fmt.Errorf("%d batch entries failed, first %s: %s %s", len(r.Failed), f.Id, f.Code, f.Message)
+}
+
+// RunBatches sends ids in chunks of MaxBatchSize and retries only the
+// entries that failed without being the sender's fault. groups has the FIFO
+// message group of each id, or is nil when there are none. Once an entry
+// fails, the entries of its group that were not sent yet wait for it to be
+// retried, so a group is never sent out of order past a failure; if it
+// fails for good they are reported as failed without being sent. Entries
+// sent in the same call as the failed one were already accepted and can't be
+// held back. An error is only returned when a whole call failed; entries
+// that were not attempted are then reported as failed as well.
+func RunBatches(ctx context.Context, ids []string, groups []string, call BatchCall) (BatchResult, error) {
+	var result BatchResult
+	group := func(i int) string {
+		if groups == nil {
+			return ""
+		}
+		return groups[i]
+	}
+
+	pending := make([]int, len(ids))
+	for i := range pending {
+		pending[i] = i
+	}
+	attempts := make(map[int]int)
+
+	wait := 200 * time.Millisecond
+	for len(pending) > 0 {
+		// a group with an entry being retried sends only that entry
+		chunk := make([]int, 0, MaxBatchSize)
+		retrying := map[string]bool{}
+		for _, i := range pending {
+			if len(chunk) == MaxBatchSize {
+				break
+			}
+			g := group(i)
+			if g != "" && retrying[g] {
+				continue
+			}
+			chunk = append(chunk, i)
+			if g != "" && attempts[i] > 0 {
+				retrying[g] = true
+			}
+		}
+
+		failures, err := call(ctx, chunk)
+		if err != nil {
+			sent := make(map[int]bool, len(chunk))
+			for _, i := range chunk {
+				sent[i] = true
+				result.Failed = append(result.Failed, BatchFailure{Id: ids[i], Code: "CallFailed", Message: err.Error()})
+			}
+			for _, i := range pending {
+				if !sent[i] {
+					result.Failed = append(result.Failed, BatchFailure{Id: ids[i], Code: "NotAttempted", Message: err.Error()})
+				}
+			}
+			return result, err
+		}
+
+		done := make(map[int]bool, len(chunk))
+		// failedGroups holds the id whose failure ends each group
+		failedGroups := map[string]string{}
+		retried := false
+		for _, i := range chunk {
+			attempts[i]++
+			failure, failed := failures[i]
+			switch {
+			case !failed:
+				result.Successful = append(result.Successful, ids[i])
+				done[i] = true
+			case !failure.SenderFault && attempts[i] < batchAttempts:
+				retried = true
+			default:
+				failure.Id = ids[i]
+				result.Failed = append(result.Failed, failure)
+				done[i] = true
+				if g := group(i); g != "" && failedGroups[g] == "" {
+					failedGroups[g] = ids[i]
+				}
+			}
+		}
+
+		next := pending[:0]
+		for _, i := range pending {
+			if done[i] {
+				continue
+			}
+			if first, ok := failedGroups[group(i)]; ok {
+				result.Failed = append(result.Failed, BatchFailure{Id: ids[i], Code: "GroupFailed", Message: fmt.Sprintf("%s, earlier in the group, failed", first)})
+				continue
+			}
+			next = append(next, i)
+		}
+		pending = next
+
+		if !retried {
+			wait = 200 * time.Millisecond
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+
+	return result, nil
+}
+
+func (m *MemoryQueue) SendMessageBatch(ctx context.Context, queueUrl string, messages []BatchMessage) (BatchResult, error) {
+	ids, groups := BatchIds(messages)
+	return RunBatches(ctx, ids, groups, func(ctx context.Context, chunk []int) (map[int]BatchFailure, error) {
+		failures := map[int]BatchFailure{}
+		for _, i := range chunk {
+			err := m.SendMessage(ctx, queueUrl, messages[i].Body, messages[i].Attributes, messages[i].Group, messages[i].DupId)
+			if errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			if err != nil {
+				failures[i] = memoryFailure(err)
+			}
+		}
+
+		return failures, nil
+	})
+}
+
+func (m *MemoryQueue) DeleteMessageBatch(ctx context.Context, queueUrl string, handles []string) (BatchResult, error) {
+	return RunBatches(ctx, handles, nil, func(ctx context.Context, chunk []int) (map[int]BatchFailure, error) {
+		failures := map[int]BatchFailure{}
+		for _, i := range chunk {
+			err := m.DeleteMessage(ctx, queueUrl, handles[i])
+			if errors.Is(err, ErrNotFound) {
+				return nil, err
+			}
+			if err != nil {
+				failures[i] = memoryFailure(err)
+			}
+		}
+
+		return failures, nil
+	})
+}
+
+// BatchIds returns the ids and the groups of messages for RunBatches.
+func BatchIds(messages []BatchMessage) (ids []string, groups []string) {
+	ids = make([]string, len(messages))
+	groups = make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.Id
+		if message.Group != nil {
+			groups[i] = *message.Group
+		}
+	}
+
+	return ids, groups
+}
+
+func memoryFailure(err error) BatchFailure {
+	return BatchFailure{
+		Code:        "MemoryQueueError",
+		Message:     err.Error(),
+		SenderFault: !Retryable(err),
+	}
+}
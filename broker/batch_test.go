@@ -0,0 +1,242 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRunBatches(t *testing.T) {
+	ids := func(n int) []string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprint("m", i)
+		}
+		return list
+	}
+	callFailed := errors.New("connection reset")
+	// evenOdd puts even and odd positions in two groups
+	evenOdd := func(n int) []string {
+		groups := make([]string, n)
+		for i := range groups {
+			groups[i] = []string{"even", "odd"}[i%2]
+		}
+		return groups
+	}
+
+	tests := []struct {
+		name   string
+		ids    []string
+		groups []string
+		// fail returns the failures of one call, attempt counts the calls
+		// that included position i
+		fail       func(i int, attempt int) (BatchFailure, bool)
+		callErr    func(call int) error
+		wantChunks []int
+		wantOk     int
+		// wantSuccessful is the order entries succeeded in, when set
+		wantSuccessful []string
+		wantFailed     map[string]string
+		wantCallErr    bool
+	}{
+		{
+			name:       "chunks of ten",
+			ids:        ids(25),
+			wantChunks: []int{10, 10, 5},
+			wantOk:     25,
+		},
+		{
+			name: "server fault retried until it succeeds",
+			ids:  ids(12),
+			fail: func(i int, attempt int) (BatchFailure, bool) {
+				return BatchFailure{Code: "InternalError"}, i%5 == 0 && attempt == 1
+			},
+			wantChunks: []int{10, 4, 1},
+			wantOk:     12,
+		},
+		{
+			name: "sender fault is not retried",
+			ids:  ids(3),
+			fail: func(i int, attempt int) (BatchFailure, bool) {
+				return BatchFailure{Code: "InvalidParameterValue", SenderFault: true}, i == 1
+			},
+			wantChunks: []int{3},
+			wantOk:     2,
+			wantFailed: map[string]string{"m1": "InvalidParameterValue"},
+		},
+		{
+			name: "server fault gives up after every attempt",
+			ids:  ids(2),
+			fail: func(i int, attempt int) (BatchFailure, bool) {
+				return BatchFailure{Code: "InternalError"}, i == 0
+			},
+			wantChunks: []int{2, 1, 1},
+			wantOk:     1,
+			wantFailed: map[string]string{"m0": "InternalError"},
+		},
+		{
+			name:   "a failed entry holds back the rest of its group",
+			ids:    ids(12),
+			groups: evenOdd(12),
+			fail: func(i int, attempt int) (BatchFailure, bool) {
+				return BatchFailure{Code: "InternalError"}, i == 4 && attempt == 1
+			},
+			// m6 and m8 went in the same call as m4, m10 waits for it
+			wantChunks:     []int{10, 2, 1},
+			wantOk:         12,
+			wantSuccessful: []string{"m0", "m1", "m2", "m3", "m5", "m6", "m7", "m8", "m9", "m4", "m11", "m10"},
+		},
+		{
+			name:   "a group stops at an entry that fails for good",
+			ids:    ids(12),
+			groups: evenOdd(12),
+			fail: func(i int, attempt int) (BatchFailure, bool) {
+				return BatchFailure{Code: "InternalError"}, i == 4
+			},
+			wantChunks: []int{10, 2, 1},
+			wantOk:     10,
+			wantFailed: map[string]string{"m4": "InternalError", "m10": "GroupFailed"},
+		},
+		{
+			name:   "a sender fault stops its group",
+			ids:    ids(12),
+			groups: evenOdd(12),
+			fail: func(i int, attempt int) (BatchFailure, bool) {
+				return BatchFailure{Code: "InvalidParameterValue", SenderFault: true}, i == 9
+			},
+			wantChunks: []int{10, 1},
+			wantOk:     10,
+			wantFailed: map[string]string{"m9": "InvalidParameterValue", "m11": "GroupFailed"},
+		},
+		{
+			name: "failed call reports the rest as not attempted",
+			ids:  ids(15),
+			callErr: func(call int) error {
+				if call == 1 {
+					return callFailed
+				}
+				return nil
+			},
+			wantChunks:  []int{10},
+			wantOk:      0,
+			wantFailed:  map[string]string{"m0": "CallFailed", "m9": "CallFailed", "m10": "NotAttempted", "m14": "NotAttempted"},
+			wantCallErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chunks []int
+			attempts := map[int]int{}
+			result, err := RunBatches(context.Background(), tt.ids, tt.groups, func(ctx context.Context, chunk []int) (map[int]BatchFailure, error) {
+				chunks = append(chunks, len(chunk))
+				if len(chunk) > MaxBatchSize {
+					t.Fatalf("chunk of %d entries", len(chunk))
+				}
+				if tt.callErr != nil {
+					if err := tt.callErr(len(chunks)); err != nil {
+						return nil, err
+					}
+				}
+
+				failures := map[int]BatchFailure{}
+				for _, i := range chunk {
+					attempts[i]++
+					if tt.fail == nil {
+						continue
+					}
+					if failure, failed := tt.fail(i, attempts[i]); failed {
+						failures[i] = failure
+					}
+				}
+				return failures, nil
+			})
+
+			if (err != nil) != tt.wantCallErr {
+				t.Fatalf("RunBatches() error = %v, want error %v", err, tt.wantCallErr)
+			}
+			if !slices.Equal(chunks, tt.wantChunks) {
+				t.Fatalf("chunk sizes = %v, want %v", chunks, tt.wantChunks)
+			}
+			if len(result.Successful) != tt.wantOk {
+				t.Fatalf("%d successful, want %d", len(result.Successful), tt.wantOk)
+			}
+			if tt.wantSuccessful != nil && !slices.Equal(result.Successful, tt.wantSuccessful) {
+				t.Fatalf("successful = %v, want %v", result.Successful, tt.wantSuccessful)
+			}
+			failed := map[string]string{}
+			for _, f := range result.Failed {
+				failed[f.Id] = f.Code
+			}
+			for id, code := range tt.wantFailed {
+				if failed[id] != code {
+					t.Fatalf("failure of %s = %q, want %q (all: %v)", id, failed[id], code, failed)
+				}
+			}
+			if tt.wantCallErr {
+				if len(result.Failed) != len(tt.ids) {
+					t.Fatalf("%d failed, every entry should be reported", len(result.Failed))
+				}
+			} else if len(result.Failed) != len(tt.wantFailed) {
+				t.Fatalf("failed = %v, want %v", failed, tt.wantFailed)
+			}
+			if (result.Err() != nil) != (len(result.Failed) > 0) {
+				t.Fatalf("Err() = %v with %d failures", result.Err(), len(result.Failed))
+			}
+		})
+	}
+}
+
+func TestMemoryBatch(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryQueue()
+	queueUrl, _ := m.CreateSqsQueue(ctx, "batch.fifo", true)
+
+	group := "g"
+	messages := make([]BatchMessage, 13)
+	for i := range messages {
+		dupId := fmt.Sprint("dup", i%11)
+		messages[i] = BatchMessage{Id: fmt.Sprint(i), Body: []byte(fmt.Sprint("body", i)), Group: &group, DupId: &dupId}
+	}
+	// no group on a FIFO queue is the sender's fault
+	messages[12].Group = nil
+
+	result, err := m.SendMessageBatch(ctx, queueUrl, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Successful) != 12 || len(result.Failed) != 1 || result.Failed[0].Id != "12" {
+		t.Fatalf("SendMessageBatch() = %+v", result)
+	}
+
+	// 11 and 12 were dropped as duplicates of 0 and 1
+	received, _ := m.Receive(ctx, queueUrl, 20, 0, time.Minute)
+	if len(received) != 11 {
+		t.Fatalf("received %d messages, want 11", len(received))
+	}
+	handles := make([]string, len(received))
+	for i, msg := range received {
+		if want := fmt.Sprint("body", i); *msg.Body != want {
+			t.Fatalf("message %d = %q, want %q", i, *msg.Body, want)
+		}
+		handles[i] = *msg.ReceiptHandle
+	}
+
+	result, err = m.DeleteMessageBatch(ctx, queueUrl, append(handles, "unknown"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Successful) != 11 || len(result.Failed) != 1 || result.Failed[0].Id != "unknown" {
+		t.Fatalf("DeleteMessageBatch() = %+v", result)
+	}
+	if stats, _ := m.Stats(ctx, queueUrl); stats != (Stats{}) {
+		t.Fatalf("Stats() after deleting everything = %+v", stats)
+	}
+
+	if _, err := m.SendMessageBatch(ctx, "memory://missing", messages); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SendMessageBatch() on a missing queue = %v, want ErrNotFound", err)
+	}
+}
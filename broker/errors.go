@@ -0,0 +1,43 @@
+// Package broker is the part of the queue that depends neither on AWS nor
+// on the pipeline: the error kinds, batches and MemoryQueue. The project
+// and the mocked SQS server both build on it.
+package broker
+
+import "errors"
+
+// Error kinds returned by every queue implementation. Check them with
+// errors.Is, the original error stays available through errors.As.
+var (
+	ErrNotFound    = errors.New("queue or message not found")
+	ErrThrottled   = errors.New("request throttled")
+	ErrAuth        = errors.New("not authorized")
+	ErrValidation  = errors.New("invalid request")
+	ErrUnavailable = errors.New("queue service unavailable")
+)
+
+type Error struct {
+	Op   string
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Kind == nil {
+		return e.Op + ": " + e.Err.Error()
+	}
+
+	return e.Op + ": " + e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+
+	return []error{e.Kind, e.Err}
+}
+
+// Retryable reports whether trying the same call again may succeed.
+func Retryable(err error) bool {
+	return errors.Is(err, ErrThrottled) || errors.Is(err, ErrUnavailable)
+}
@@ -0,0 +1,217 @@
+package fifo
+
+import (
+	"context"
+	"iter"
+	"sync"
+)
+
+type (
+	// Queue is a thread-safe FIFO queue. The zero value is an unbounded,
+	// ready to use queue; New sets a capacity after which Enqueue blocks
+	// until a consumer makes room.
+	Queue[T any] struct {
+		mu       sync.Mutex
+		notEmpty *sync.Cond
+		notFull  *sync.Cond
+		capacity int
+		length   int
+		front    *node[T]
+		rear     *node[T]
+	}
+
+	node[T any] struct {
+		data T
+		next *node[T]
+	}
+)
+
+// New returns a queue holding at most capacity items. A capacity lower
+// than 1 means the queue is unbounded.
+func New[T any](capacity int) *Queue[T] {
+	return &Queue[T]{capacity: capacity}
+}
+
+func (q *Queue[T]) init() {
+	if q.notEmpty == nil {
+		q.notEmpty = sync.NewCond(&q.mu)
+		q.notFull = sync.NewCond(&q.mu)
+	}
+}
+
+func (q *Queue[T]) full() bool {
+	return q.capacity > 0 && q.length >= q.capacity
+}
+
+func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.length
+}
+
+func (q *Queue[T]) Cap() int {
+	return q.capacity
+}
+
+// Enqueue adds data to the rear of the queue, waiting for room while the
+// queue is full. It returns the context error if ctx ends first.
+func (q *Queue[T]) Enqueue(ctx context.Context, data T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.init()
+
+	if q.full() {
+		stop := context.AfterFunc(ctx, q.wake)
+		defer stop()
+
+		for q.full() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			q.notFull.Wait()
+		}
+	}
+
+	q.push(data)
+	return nil
+}
+
+// TryEnqueue adds data without waiting and reports false if the queue is full.
+func (q *Queue[T]) TryEnqueue(data T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.init()
+
+	if q.full() {
+		return false
+	}
+
+	q.push(data)
+	return true
+}
+
+// Dequeue removes the front item, waiting for one while the queue is empty.
+// It returns the context error if ctx ends first.
+func (q *Queue[T]) Dequeue(ctx context.Context) (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.init()
+
+	if q.length == 0 {
+		stop := context.AfterFunc(ctx, q.wake)
+		defer stop()
+
+		for q.length == 0 {
+			if err := ctx.Err(); err != nil {
+				var zero T
+				return zero, err
+			}
+			q.notEmpty.Wait()
+		}
+	}
+
+	return q.pop(), nil
+}
+
+// TryDequeue removes the front item without waiting. The boolean is false
+// when the queue is empty.
+func (q *Queue[T]) TryDequeue() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.init()
+
+	if q.length == 0 {
+		var zero T
+		return zero, false
+	}
+
+	return q.pop(), true
+}
+
+// Peek returns the front item without removing it.
+func (q *Queue[T]) Peek() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.length == 0 {
+		var zero T
+		return zero, false
+	}
+
+	return q.front.data, true
+}
+
+// Drain empties the queue and returns its items in order.
+func (q *Queue[T]) Drain() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.init()
+
+	items := make([]T, 0, q.length)
+	for n := q.front; n != nil; n = n.next {
+		items = append(items, n.data)
+	}
+
+	q.front = nil
+	q.rear = nil
+	q.length = 0
+	q.notFull.Broadcast()
+
+	return items
+}
+
+// All iterates over a snapshot of the queue from front to rear. Changes made
+// while iterating are not reflected.
+func (q *Queue[T]) All() iter.Seq[T] {
+	q.mu.Lock()
+	items := make([]T, 0, q.length)
+	for n := q.front; n != nil; n = n.next {
+		items = append(items, n.data)
+	}
+	q.mu.Unlock()
+
+	return func(yield func(T) bool) {
+		for _, item := range items {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+func (q *Queue[T]) push(data T) {
+	temp := &node[T]{data: data}
+
+	if q.length == 0 {
+		q.front = temp
+	} else {
+		q.rear.next = temp
+	}
+
+	q.rear = temp
+	q.length++
+	q.notEmpty.Broadcast()
+}
+
+func (q *Queue[T]) pop() T {
+	result := q.front.data
+	q.front = q.front.next
+
+	if q.front == nil {
+		q.rear = nil
+	}
+
+	q.length--
+	q.notFull.Broadcast()
+	return result
+}
+
+// wake releases every waiter so they can notice a cancelled context.
+func (q *Queue[T]) wake() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
@@ -0,0 +1,197 @@
+package fifo
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrder(t *testing.T) {
+	var q Queue[int]
+	for i := range 5 {
+		if !q.TryEnqueue(i) {
+			t.Fatalf("TryEnqueue(%d) refused on an unbounded queue", i)
+		}
+	}
+
+	if got := slices.Collect(q.All()); !slices.Equal(got, []int{0, 1, 2, 3, 4}) {
+		t.Fatalf("All() = %v, want 0..4", got)
+	}
+	if front, ok := q.Peek(); !ok || front != 0 {
+		t.Fatalf("Peek() = %d, %v, want 0, true", front, ok)
+	}
+
+	for want := range 5 {
+		got, err := q.Dequeue(context.Background())
+		if err != nil || got != want {
+			t.Fatalf("Dequeue() = %d, %v, want %d", got, err, want)
+		}
+	}
+	if _, ok := q.TryDequeue(); ok {
+		t.Fatal("TryDequeue() on an empty queue reported an item")
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	q := New[int](2)
+	q.TryEnqueue(1)
+	q.TryEnqueue(2)
+	if q.TryEnqueue(3) {
+		t.Fatal("TryEnqueue() went past the capacity")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.Enqueue(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Enqueue() on a full queue = %v, want the context error", err)
+	}
+
+	if got := q.Drain(); !slices.Equal(got, []int{1, 2}) {
+		t.Fatalf("Drain() = %v, want [1 2]", got)
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Len() after Drain = %d", q.Len())
+	}
+}
+
+func TestDequeueWokenByEnqueue(t *testing.T) {
+	var q Queue[string]
+	got := make(chan string)
+	go func() {
+		item, err := q.Dequeue(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		got <- item
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	q.TryEnqueue("invoice")
+
+	select {
+	case item := <-got:
+		if item != "invoice" {
+			t.Fatalf("Dequeue() = %q, want invoice", item)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Dequeue() was not woken by Enqueue")
+	}
+}
+
+func TestEnqueueWokenByDequeue(t *testing.T) {
+	q := New[int](1)
+	q.TryEnqueue(1)
+
+	done := make(chan error)
+	go func() { done <- q.Enqueue(context.Background(), 2) }()
+
+	time.Sleep(10 * time.Millisecond)
+	if item, _ := q.TryDequeue(); item != 1 {
+		t.Fatalf("TryDequeue() = %d, want 1", item)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue() was not woken by Dequeue")
+	}
+	if item, _ := q.TryDequeue(); item != 2 {
+		t.Fatalf("TryDequeue() = %d, want 2", item)
+	}
+}
+
+func TestDequeueCancelled(t *testing.T) {
+	var q Queue[int]
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		_, err := q.Dequeue(ctx)
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Dequeue() = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Dequeue() kept waiting after its context was cancelled")
+	}
+}
+
+// TestConcurrent is meant for go test -race: every item sent by the
+// producers is received exactly once.
+func TestConcurrent(t *testing.T) {
+	const producers, consumers, perProducer = 4, 4, 500
+
+	q := New[[2]int](16)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var sent sync.WaitGroup
+	for p := range producers {
+		sent.Add(1)
+		go func() {
+			defer sent.Done()
+			for i := range perProducer {
+				if err := q.Enqueue(ctx, [2]int{p, i}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	var mu sync.Mutex
+	received := make([][]int, producers)
+	var wg sync.WaitGroup
+	for range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				item, err := q.Dequeue(ctx)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				received[item[0]] = append(received[item[0]], item[1])
+				total := 0
+				for _, r := range received {
+					total += len(r)
+				}
+				mu.Unlock()
+				if total == producers*perProducer {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	sent.Wait()
+	wg.Wait()
+
+	for p, items := range received {
+		if len(items) != perProducer {
+			t.Fatalf("producer %d: received %d items, want %d", p, len(items), perProducer)
+		}
+		// consumers race between Dequeue and the lock, so only the set of
+		// items is checked, not the order they were appended in
+		slices.Sort(items)
+		for i, item := range items {
+			if item != i {
+				t.Fatalf("producer %d: item %d missing or repeated", p, i)
+			}
+		}
+	}
+}
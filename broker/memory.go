@@ -0,0 +1,489 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"test/starkbank/broker/fifo"
+	"test/starkbank/logging"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+var logger = logging.For("broker")
+
+const (
+	dedupWindow     = 5 * time.Minute
+	maxReceiveBatch = 8
+)
+
+type (
+	// Stats are the approximate message counts of a queue.
+	Stats struct {
+		// Visible messages are waiting to be received.
+		Visible int
+		// InFlight messages were received and not deleted yet.
+		InFlight int
+	}
+
+	// MemoryQueue is an in-process stand-in for SQS. Pending messages are kept
+	// on a fifo.Queue per message group, and messages of a FIFO group are
+	// only handed out once every earlier message of that group is deleted.
+	MemoryQueue struct {
+		VisibilityTimeout time.Duration
+		WaitTime          time.Duration
+
+		mu     sync.Mutex
+		queues map[string]*memQueue
+	}
+
+	memQueue struct {
+		fifo       bool
+		order      []string
+		groups     map[string]*memGroup
+		dedup      map[string]memSent
+		deadLetter *memRedrive
+	}
+
+	// memSent is a deduplication id seen inside the window and the message
+	// it was first sent as.
+	memSent struct {
+		id     string
+		sentAt time.Time
+	}
+
+	memRedrive struct {
+		queueUrl        string
+		maxReceiveCount int
+	}
+
+	memGroup struct {
+		received []*memMessage
+		pending  fifo.Queue[*memMessage]
+	}
+
+	memMessage struct {
+		id           string
+		group        string
+		dedupId      string
+		body         string
+		attributes   map[string]types.MessageAttributeValue
+		handle       string
+		visibleAt    time.Time
+		receiveCount int
+	}
+)
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		VisibilityTimeout: 30 * time.Second,
+		WaitTime:          20 * time.Second,
+		queues:            map[string]*memQueue{},
+	}
+}
+
+func (m *MemoryQueue) CreateSqsQueue(ctx context.Context, queueName string, isFifo bool) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queueUrl := memoryUrl(queueName)
+	if _, ok := m.queues[queueUrl]; !ok {
+		m.queues[queueUrl] = &memQueue{
+			fifo:   isFifo,
+			groups: map[string]*memGroup{},
+			dedup:  map[string]memSent{},
+		}
+	}
+
+	return queueUrl, nil
+}
+
+func (m *MemoryQueue) GetQueue(ctx context.Context, queueName string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	queueUrl := memoryUrl(queueName)
+	if _, ok := m.queues[queueUrl]; !ok {
+		return "", notFound("get queue "+queueName, queueUrl)
+	}
+
+	return queueUrl, nil
+}
+
+func (m *MemoryQueue) GetOrCreateQueue(ctx context.Context, queueName string, isFifo bool) (string, error) {
+	return m.CreateSqsQueue(ctx, queueName, isFifo)
+}
+
+func (m *MemoryQueue) SendMessage(ctx context.Context, queueUrl string, message []byte, attributes map[string]types.MessageAttributeValue, group *string, dupId *string) error {
+	_, err := m.Send(ctx, queueUrl, message, attributes, group, dupId)
+	return err
+}
+
+// Send is SendMessage returning the message id. A message dropped as a
+// duplicate gets the id of the one it duplicates, like on SQS.
+func (m *MemoryQueue) Send(ctx context.Context, queueUrl string, message []byte, attributes map[string]types.MessageAttributeValue, group *string, dupId *string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.queues[queueUrl]
+	if !ok {
+		return "", notFound("send message", queueUrl)
+	}
+
+	id := newId()
+	groupId := id
+	var dedupId string
+	if q.fifo {
+		if group == nil {
+			return "", &Error{Op: "send message", Kind: ErrValidation, Err: errors.New("MessageGroupId is required for fifo queues")}
+		}
+		groupId = *group
+
+		dedupId = contentDedup(message)
+		if dupId != nil {
+			dedupId = *dupId
+		}
+		now := time.Now()
+		for key, sent := range q.dedup {
+			if now.Sub(sent.sentAt) >= dedupWindow {
+				delete(q.dedup, key)
+			}
+		}
+		if sent, seen := q.dedup[dedupId]; seen {
+			return sent.id, nil
+		}
+	}
+
+	if err := q.push(&memMessage{id: id, group: groupId, dedupId: dedupId, body: string(message), attributes: attributes}); err != nil {
+		return "", err
+	}
+	if q.fifo {
+		q.dedup[dedupId] = memSent{id: id, sentAt: time.Now()}
+	}
+	return id, nil
+}
+
+func (q *memQueue) push(msg *memMessage) error {
+	g, ok := q.groups[msg.group]
+	if !ok {
+		g = &memGroup{}
+		q.groups[msg.group] = g
+		q.order = append(q.order, msg.group)
+	}
+	if !g.pending.TryEnqueue(msg) {
+		q.removeIfEmpty(msg.group)
+		return &Error{Op: "send message", Kind: ErrThrottled, Err: fmt.Errorf("message group %s is full", msg.group)}
+	}
+
+	return nil
+}
+
+// removeIfEmpty forgets a group once it has no messages left, so receives
+// don't walk groups that will never deliver again.
+func (q *memQueue) removeIfEmpty(groupId string) {
+	g, ok := q.groups[groupId]
+	if !ok || len(g.received) > 0 || g.pending.Len() > 0 {
+		return
+	}
+
+	delete(q.groups, groupId)
+	for i, id := range q.order {
+		if id == groupId {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *MemoryQueue) GetMessages(ctx context.Context, queueUrl string) ([]types.Message, error) {
+	return m.Receive(ctx, queueUrl, maxReceiveBatch, m.WaitTime, m.VisibilityTimeout)
+}
+
+// Receive waits up to wait for messages and returns at most max of them,
+// hidden from other receives for visibility.
+func (m *MemoryQueue) Receive(ctx context.Context, queueUrl string, max int, wait time.Duration, visibility time.Duration) ([]types.Message, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		messages, err := m.receive(queueUrl, max, visibility)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (m *MemoryQueue) receive(queueUrl string, max int, visibility time.Duration) ([]types.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.queues[queueUrl]
+	if !ok {
+		return nil, notFound("receive messages", queueUrl)
+	}
+
+	now := time.Now()
+	var messages []types.Message
+	var emptied []string
+	defer func() {
+		for _, groupId := range emptied {
+			q.removeIfEmpty(groupId)
+		}
+	}()
+
+	for _, groupId := range q.order {
+		g := q.groups[groupId]
+		if g.blocked(now) {
+			continue
+		}
+		m.moveToDeadLetter(q, g)
+		if len(g.received) == 0 && g.pending.Len() == 0 {
+			emptied = append(emptied, groupId)
+			continue
+		}
+
+		for _, msg := range g.received {
+			if len(messages) == max {
+				return messages, nil
+			}
+			messages = append(messages, deliver(msg, now, visibility))
+		}
+
+		for {
+			if len(messages) == max {
+				return messages, nil
+			}
+			msg, ok := g.pending.TryDequeue()
+			if !ok {
+				break
+			}
+			g.received = append(g.received, msg)
+			messages = append(messages, deliver(msg, now, visibility))
+
+			if !q.fifo {
+				break
+			}
+		}
+	}
+
+	return messages, nil
+}
+
+// moveToDeadLetter hands the messages of a group that were received more
+// than maxReceiveCount times over to the dead-letter queue, like SQS does on
+// the next receive attempt. A message the dead-letter queue refuses stays
+// where it is.
+func (m *MemoryQueue) moveToDeadLetter(q *memQueue, g *memGroup) {
+	if q.deadLetter == nil {
+		return
+	}
+	dlq, ok := m.queues[q.deadLetter.queueUrl]
+	if !ok {
+		return
+	}
+
+	kept := g.received[:0]
+	for _, msg := range g.received {
+		if msg.receiveCount < q.deadLetter.maxReceiveCount {
+			kept = append(kept, msg)
+			continue
+		}
+
+		dead := &memMessage{id: msg.id, group: msg.group, dedupId: msg.dedupId, body: msg.body, attributes: msg.attributes}
+		if !dlq.fifo {
+			dead.group = msg.id
+		}
+		if err := dlq.push(dead); err != nil {
+			logger.Error("message not moved to the dead-letter queue", "message_id", msg.id, "err", err)
+			kept = append(kept, msg)
+		}
+	}
+	g.received = kept
+}
+
+// blocked reports whether a previous receive of the group is still in flight.
+func (g *memGroup) blocked(now time.Time) bool {
+	for _, msg := range g.received {
+		if now.Before(msg.visibleAt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func deliver(msg *memMessage, now time.Time, visibility time.Duration) types.Message {
+	msg.handle = newId()
+	msg.visibleAt = now.Add(visibility)
+	msg.receiveCount++
+
+	attributes := map[string]string{
+		string(types.MessageSystemAttributeNameApproximateReceiveCount): strconv.Itoa(msg.receiveCount),
+	}
+	if msg.group != msg.id {
+		attributes[string(types.MessageSystemAttributeNameMessageGroupId)] = msg.group
+	}
+	if msg.dedupId != "" {
+		attributes[string(types.MessageSystemAttributeNameMessageDeduplicationId)] = msg.dedupId
+	}
+
+	return types.Message{
+		MessageId:         aws.String(msg.id),
+		ReceiptHandle:     aws.String(msg.handle),
+		Body:              aws.String(msg.body),
+		Attributes:        attributes,
+		MessageAttributes: msg.attributes,
+	}
+}
+
+func (m *MemoryQueue) PurgeQueue(ctx context.Context, queueUrl string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.queues[queueUrl]
+	if !ok {
+		return notFound("purge queue", queueUrl)
+	}
+	q.groups = map[string]*memGroup{}
+	q.order = nil
+
+	return nil
+}
+
+func (m *MemoryQueue) DeleteMessage(ctx context.Context, queueUrl string, handle string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.queues[queueUrl]
+	if !ok {
+		return notFound("delete message", queueUrl)
+	}
+
+	for _, groupId := range q.order {
+		g := q.groups[groupId]
+		for y, msg := range g.received {
+			if msg.handle != handle {
+				continue
+			}
+			g.received = append(g.received[:y], g.received[y+1:]...)
+			q.removeIfEmpty(groupId)
+			return nil
+		}
+	}
+
+	return invalidHandle("delete message", handle)
+}
+
+func (m *MemoryQueue) ChangeMessageVisibility(ctx context.Context, queueUrl string, handle string, timeout time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.queues[queueUrl]
+	if !ok {
+		return notFound("change message visibility", queueUrl)
+	}
+
+	now := time.Now()
+	for _, g := range q.groups {
+		for _, msg := range g.received {
+			if msg.handle != handle {
+				continue
+			}
+			if !now.Before(msg.visibleAt) {
+				return &Error{Op: "change message visibility", Kind: ErrValidation, Err: fmt.Errorf("message %s is no longer in flight", msg.id)}
+			}
+			msg.visibleAt = now.Add(timeout)
+			return nil
+		}
+	}
+
+	return invalidHandle("change message visibility", handle)
+}
+
+// Stats counts the messages waiting in queueUrl and the ones received and
+// still hidden.
+func (m *MemoryQueue) Stats(ctx context.Context, queueUrl string) (Stats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.queues[queueUrl]
+	if !ok {
+		return Stats{}, notFound("get queue attributes", queueUrl)
+	}
+
+	now := time.Now()
+	var stats Stats
+	for _, g := range q.groups {
+		stats.Visible += g.pending.Len()
+		for _, msg := range g.received {
+			if now.Before(msg.visibleAt) {
+				stats.InFlight++
+			} else {
+				stats.Visible++
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+func (m *MemoryQueue) AttachDeadLetterQueue(ctx context.Context, queueUrl string, dlqUrl string, maxReceiveCount int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, ok := m.queues[queueUrl]
+	if !ok {
+		return notFound("set redrive policy", queueUrl)
+	}
+	dlq, ok := m.queues[dlqUrl]
+	if !ok {
+		return notFound("set redrive policy", dlqUrl)
+	}
+	if q.fifo != dlq.fifo {
+		return &Error{Op: "set redrive policy", Kind: ErrValidation, Err: fmt.Errorf("dead-letter queue of %s must have the same type", queueUrl)}
+	}
+	if maxReceiveCount < 1 {
+		return &Error{Op: "set redrive policy", Kind: ErrValidation, Err: errors.New("maxReceiveCount must be at least 1")}
+	}
+
+	q.deadLetter = &memRedrive{queueUrl: dlqUrl, maxReceiveCount: maxReceiveCount}
+	return nil
+}
+
+func notFound(op string, queueUrl string) error {
+	return &Error{Op: op, Kind: ErrNotFound, Err: fmt.Errorf("memory queue does not exist: %s", queueUrl)}
+}
+
+func invalidHandle(op string, handle string) error {
+	return &Error{Op: op, Kind: ErrValidation, Err: fmt.Errorf("receipt handle is not valid: %s", handle)}
+}
+
+func memoryUrl(queueName string) string {
+	return "memory://" + strings.TrimPrefix(queueName, "/")
+}
+
+func contentDedup(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func newId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -2,21 +2,42 @@ package config
 
 import (
 	"context"
-	"os"
-	"test/starkbank/helpers"
+	"fmt"
+	"test/starkbank/logging"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 )
 
-var logFile = "/logs/aws_config.txt"
+var logger = logging.For("aws")
 
-func ConfigAWS(ctx context.Context) aws.Config {
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion("us-east-1"), config.WithSharedConfigProfile("AdminDev"))
+// ConfigAWS loads the AWS config for settings. Static keys take the place
+// of the shared profile, and an endpoint sends every call to it instead of
+// AWS, for LocalStack or ElasticMQ. The credentials are resolved once here
+// so a missing or broken source fails at startup.
+func ConfigAWS(ctx context.Context, settings AWS) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{config.WithRegion(settings.Region)}
+	switch {
+	case settings.AccessKeyId != "":
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(settings.AccessKeyId, settings.SecretAccessKey, settings.SessionToken)))
+	case settings.Profile != "":
+		opts = append(opts, config.WithSharedConfigProfile(settings.Profile))
+	}
+	if settings.Endpoint != "" {
+		opts = append(opts, config.WithBaseEndpoint(settings.Endpoint))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("error loading aws config: %w", err)
+	}
+
+	creds, err := cfg.Credentials.Retrieve(ctx)
 	if err != nil {
-		helpers.LogError(logFile, err.Error())
-		os.Exit(1)
+		return aws.Config{}, fmt.Errorf("error resolving aws credentials: %w", err)
 	}
+	logger.Info("aws credentials resolved", "source", creds.Source, "region", cfg.Region, "endpoint", settings.Endpoint)
 
-	return cfg
+	return cfg, nil
 }
\ No newline at end of file
@@ -0,0 +1,376 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"test/starkbank/generator"
+	"test/starkbank/logging"
+	"test/starkbank/money"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type (
+	// Config is every setting shared by the project and the mocked binaries,
+	// loaded once at startup by Load. Settings only one binary reads are its
+	// own Section.
+	Config struct {
+		// EnvFile is the .env file that was read, empty when none was found.
+		EnvFile string
+
+		Queue     Queue
+		AWS       AWS
+		Api       Api
+		Sqs       Sqs
+		DB        DB
+		Generator generator.Config
+		Log       logging.Config
+
+		// Money is how amounts are written in JSON, every process talking to
+		// the API or the queue must use the same.
+		Money money.Mode
+	}
+
+	Queue struct {
+		// Driver is "sqs" or "memory".
+		Driver          string
+		Name            string
+		MaxReceiveCount int
+	}
+
+	AWS struct {
+		Region string
+		// Profile is the shared config profile, ignored when AccessKeyId
+		// is set.
+		Profile         string
+		AccessKeyId     string
+		SecretAccessKey string
+		SessionToken    string
+		// Endpoint replaces the AWS endpoint, for LocalStack or ElasticMQ.
+		Endpoint string
+	}
+
+	// Api is the mocked API, as served and as called by the project.
+	Api struct {
+		Url               string
+		Port              int
+		IdempotencyWindow time.Duration
+		// IdempotencyLease is how long a key stays reserved by a request
+		// that never stored its response.
+		IdempotencyLease time.Duration
+	}
+
+	// Sqs is the SQS-compatible server in mocked/sqs.
+	Sqs struct {
+		Port int
+	}
+
+	DB struct {
+		Connection string
+		Host       string
+		Port       int
+		Name       string
+		User       string
+		Password   string
+	}
+)
+
+// queueName matches the names SQS accepts for FIFO queues.
+var queueName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,75}\.fifo$`)
+
+func Default() Config {
+	return Config{
+		Queue: Queue{
+			Driver:          "sqs",
+			Name:            "invoices.fifo",
+			MaxReceiveCount: 5,
+		},
+		AWS: AWS{
+			Region:  "us-east-1",
+			Profile: "AdminDev",
+		},
+		Api: Api{
+			Url:               "http://localhost:9090",
+			Port:              9090,
+			IdempotencyWindow: 24 * time.Hour,
+			IdempotencyLease:  time.Minute,
+		},
+		Sqs: Sqs{
+			Port: 9324,
+		},
+		DB: DB{
+			Connection: "mysql",
+			Host:       "127.0.0.1",
+			Port:       3306,
+			Name:       "mocked_api",
+			User:       "root",
+		},
+		Generator: generator.DefaultConfig(),
+		Log:       logging.DefaultConfig(),
+	}
+}
+
+// Load builds the config from, lowest to highest precedence: the defaults,
+// the .env file, environment variables and the flags registered with
+// RegisterFlags. flags may be nil when the command takes none. sections are
+// read from the same sources after Config. Every invalid setting is
+// reported, not only the first.
+func Load(flags *Flags, sections ...Section) (Config, error) {
+	values := map[string]string{}
+	origins := map[string]string{}
+
+	envFile, err := findEnvFile(flags)
+	if err != nil {
+		return Config{}, err
+	}
+	if envFile != "" {
+		file, err := readEnvFile(envFile)
+		if err != nil {
+			return Config{}, err
+		}
+		for key, value := range file {
+			values[key], origins[key] = value, envFile
+		}
+	}
+
+	lookup := func(keys []Key) {
+		for _, k := range keys {
+			if value, ok := os.LookupEnv(k.Name); ok {
+				values[k.Name], origins[k.Name] = value, "environment"
+			}
+		}
+	}
+	lookup(keys)
+	for _, section := range sections {
+		lookup(section.Keys)
+	}
+
+	if flags != nil {
+		flags.set.Visit(func(f *flag.Flag) {
+			if key, ok := flags.keys[f.Name]; ok {
+				values[key], origins[key] = f.Value.String(), "-"+f.Name
+			}
+		})
+	}
+
+	cfg, err := build(&Values{values: values, origins: origins}, sections)
+	cfg.EnvFile = envFile
+	return cfg, err
+}
+
+// build parses values over the defaults. A value that doesn't parse is
+// reported with its key and where it came from, and the default is kept so
+// the remaining keys are still checked.
+func build(v *Values, sections []Section) (Config, error) {
+	cfg := Default()
+
+	v.String("QUEUE_DRIVER", &cfg.Queue.Driver)
+	v.String("QUEUE_NAME", &cfg.Queue.Name)
+	v.Int("QUEUE_MAX_RECEIVE_COUNT", &cfg.Queue.MaxReceiveCount)
+
+	v.String("AWS_REGION", &cfg.AWS.Region)
+	v.String("AWS_PROFILE", &cfg.AWS.Profile)
+	v.String("AWS_ACCESS_KEY_ID", &cfg.AWS.AccessKeyId)
+	v.String("AWS_SECRET_ACCESS_KEY", &cfg.AWS.SecretAccessKey)
+	v.String("AWS_SESSION_TOKEN", &cfg.AWS.SessionToken)
+	v.String("AWS_ENDPOINT_URL", &cfg.AWS.Endpoint)
+
+	v.String("MOCKED_API", &cfg.Api.Url)
+	v.Int("API_PORT", &cfg.Api.Port)
+	v.Duration("IDEMPOTENCY_WINDOW", &cfg.Api.IdempotencyWindow)
+	v.Duration("IDEMPOTENCY_LEASE", &cfg.Api.IdempotencyLease)
+
+	v.Int("SQS_PORT", &cfg.Sqs.Port)
+
+	v.String("DB_CONNECTION", &cfg.DB.Connection)
+	v.String("DB_HOST", &cfg.DB.Host)
+	v.Int("DB_PORT", &cfg.DB.Port)
+	v.String("DB_NAME", &cfg.DB.Name)
+	v.String("DB_USER", &cfg.DB.User)
+	v.String("DB_PASSWORD", &cfg.DB.Password)
+
+	if s, ok := v.Lookup("GENERATOR_SEED"); ok {
+		seed, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			v.Fail("GENERATOR_SEED", errors.New("not a positive integer"))
+		} else {
+			cfg.Generator.Seed = seed
+		}
+	}
+	if s, ok := v.Lookup("GENERATOR_AMOUNT"); ok {
+		amounts, err := generator.ParseDistribution(s)
+		if err != nil {
+			v.Fail("GENERATOR_AMOUNT", err)
+		} else {
+			cfg.Generator.Amounts = amounts
+		}
+	}
+
+	if s, ok := v.Lookup("LOG_LEVEL"); ok {
+		if err := cfg.Log.Level.UnmarshalText([]byte(s)); err != nil {
+			v.Fail("LOG_LEVEL", errors.New("use debug, info, warn or error"))
+		}
+	}
+	if s, ok := v.Lookup("LOG_FORMAT"); ok {
+		cfg.Log.Format = strings.ToLower(s)
+	}
+	v.String("LOG_OUTPUT", &cfg.Log.Output)
+	maxSizeMb := int(cfg.Log.MaxSize >> 20)
+	v.Int("LOG_MAX_SIZE_MB", &maxSizeMb)
+	cfg.Log.MaxSize = int64(maxSizeMb) << 20
+	v.Int("LOG_MAX_FILES", &cfg.Log.MaxFiles)
+
+	if s, ok := v.Lookup("MONEY_JSON"); ok {
+		m, err := money.ParseMode(s)
+		if err != nil {
+			v.Fail("MONEY_JSON", errors.New("use decimal or cents"))
+		} else {
+			cfg.Money = m
+		}
+	}
+
+	v.Section("queue", cfg.Queue.Validate())
+	v.Section("aws", cfg.AWS.Validate())
+	v.Section("api", cfg.Api.Validate())
+	v.Section("sqs", cfg.Sqs.Validate())
+	v.Section("db", cfg.DB.Validate())
+	v.Section("log", cfg.Log.Validate())
+
+	for _, section := range sections {
+		section.Load(v)
+	}
+
+	return cfg, v.Err()
+}
+
+func (q Queue) Validate() error {
+	if q.Driver != "sqs" && q.Driver != "memory" {
+		return fmt.Errorf("QUEUE_DRIVER must be sqs or memory, got %q", q.Driver)
+	}
+	if !queueName.MatchString(q.Name) {
+		return fmt.Errorf("QUEUE_NAME %q must be up to 80 letters, digits, - or _ ending in .fifo", q.Name)
+	}
+	if q.MaxReceiveCount < 1 || q.MaxReceiveCount > 1000 {
+		return fmt.Errorf("QUEUE_MAX_RECEIVE_COUNT must be between 1 and 1000")
+	}
+
+	return nil
+}
+
+func (a AWS) Validate() error {
+	if a.Region == "" {
+		return fmt.Errorf("AWS_REGION can't be empty")
+	}
+	if (a.AccessKeyId == "") != (a.SecretAccessKey == "") {
+		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
+	}
+	if a.SessionToken != "" && a.AccessKeyId == "" {
+		return fmt.Errorf("AWS_SESSION_TOKEN needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if a.Endpoint != "" {
+		u, err := url.Parse(a.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("AWS_ENDPOINT_URL must be an http(s) url, got %q", a.Endpoint)
+		}
+	}
+
+	return nil
+}
+
+func (a Api) Validate() error {
+	u, err := url.Parse(a.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("MOCKED_API must be an http(s) url, got %q", a.Url)
+	}
+	if a.Port < 1 || a.Port > 65535 {
+		return fmt.Errorf("API_PORT must be between 1 and 65535")
+	}
+	if a.IdempotencyWindow <= 0 {
+		return fmt.Errorf("IDEMPOTENCY_WINDOW must be positive")
+	}
+	if a.IdempotencyLease < time.Second || a.IdempotencyLease > a.IdempotencyWindow {
+		return fmt.Errorf("IDEMPOTENCY_LEASE must be between 1s and IDEMPOTENCY_WINDOW")
+	}
+
+	return nil
+}
+
+func (s Sqs) Validate() error {
+	if s.Port < 1 || s.Port > 65535 {
+		return fmt.Errorf("SQS_PORT must be between 1 and 65535")
+	}
+
+	return nil
+}
+
+func (d DB) Validate() error {
+	if d.Connection != "mysql" {
+		return fmt.Errorf("DB_CONNECTION %q is not supported, only mysql is", d.Connection)
+	}
+	if d.Host == "" || d.Name == "" || d.User == "" {
+		return fmt.Errorf("DB_HOST, DB_NAME and DB_USER are required")
+	}
+	if d.Port < 1 || d.Port > 65535 {
+		return fmt.Errorf("DB_PORT must be between 1 and 65535")
+	}
+
+	return nil
+}
+
+// Addr is the host:port of the database.
+func (d DB) Addr() string {
+	return d.Host + ":" + strconv.Itoa(d.Port)
+}
+
+// findEnvFile returns the -env-file flag or ENV_FILE when set, both must
+// exist. Otherwise the first .env found in the working directory, its
+// parent, the executable's directory or its parent; none is fine.
+func findEnvFile(flags *Flags) (string, error) {
+	explicit := os.Getenv("ENV_FILE")
+	if flags != nil && *flags.envFile != "" {
+		explicit = *flags.envFile
+	}
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return "", fmt.Errorf("env file: %w", err)
+		}
+		return explicit, nil
+	}
+
+	candidates := []string{".env", filepath.Join("..", ".env")}
+	if exe, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exe)
+		candidates = append(candidates, filepath.Join(dir, ".env"), filepath.Join(dir, "..", ".env"))
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return "", nil
+}
+
+func readEnvFile(path string) (map[string]string, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("env")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	for _, key := range v.AllKeys() {
+		values[strings.ToUpper(key)] = v.GetString(key)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"flag"
+	"strings"
+)
+
+// Key is a setting that can come from the .env file, the environment or a
+// flag named after it: QUEUE_NAME is -queue-name.
+type Key struct {
+	Name  string
+	Usage string
+}
+
+// keys are the settings of Config, sections bring their own.
+var keys = []Key{
+	{"QUEUE_DRIVER", "queue broker, sqs or memory"},
+	{"QUEUE_NAME", "name of the FIFO queue, ending in .fifo"},
+	{"QUEUE_MAX_RECEIVE_COUNT", "receives before a message is moved to the dead-letter queue"},
+	{"AWS_REGION", "AWS region of the queue"},
+	{"AWS_PROFILE", "shared config profile used for AWS credentials"},
+	{"AWS_ACCESS_KEY_ID", "static AWS access key, replaces the profile"},
+	{"AWS_SECRET_ACCESS_KEY", "secret of AWS_ACCESS_KEY_ID"},
+	{"AWS_SESSION_TOKEN", "session token of temporary static credentials"},
+	{"AWS_ENDPOINT_URL", "SQS endpoint such as http://localhost:4566, empty uses AWS"},
+	{"MOCKED_API", "base url of the mocked API"},
+	{"API_PORT", "port the mocked API listens on"},
+	{"IDEMPOTENCY_WINDOW", "how long the mocked API replays a repeated Idempotency-Key"},
+	{"IDEMPOTENCY_LEASE", "how long an Idempotency-Key stays reserved by a request that never finished"},
+	{"SQS_PORT", "port the local SQS server listens on"},
+	{"DB_CONNECTION", "database driver, only mysql"},
+	{"DB_HOST", "database host"},
+	{"DB_PORT", "database port"},
+	{"DB_NAME", "database name"},
+	{"DB_USER", "database user"},
+	{"DB_PASSWORD", "database password"},
+	{"GENERATOR_SEED", "seed of the invoice generator, 0 picks one from the clock"},
+	{"GENERATOR_AMOUNT", "amount distribution: fixed:x, uniform:a,b or lognormal:mu,sigma"},
+	{"LOG_LEVEL", "debug, info, warn or error"},
+	{"LOG_FORMAT", "text or json"},
+	{"LOG_OUTPUT", "stdout, stderr or a file path"},
+	{"LOG_MAX_SIZE_MB", "rotate the log file at this size"},
+	{"LOG_MAX_FILES", "rotated log files kept"},
+	{"MONEY_JSON", "how amounts are written in JSON: decimal (4000.10) or cents (400010)"},
+}
+
+// Flags are the config flags registered on a flag set, read back by Load
+// once the set is parsed.
+type Flags struct {
+	set     *flag.FlagSet
+	envFile *string
+	// keys maps flag names back to config keys
+	keys map[string]string
+}
+
+// RegisterFlags adds -env-file and a flag for every config key to set, and
+// for the extra keys of the sections the binary loads.
+func RegisterFlags(set *flag.FlagSet, extra ...Key) *Flags {
+	f := &Flags{
+		set:     set,
+		envFile: set.String("env-file", "", "path of the .env file, by default the first .env in . or .."),
+		keys:    map[string]string{},
+	}
+	for _, k := range append(keys[:len(keys):len(keys)], extra...) {
+		name := strings.ToLower(strings.ReplaceAll(k.Name, "_", "-"))
+		set.String(name, "", k.Usage+" ("+k.Name+")")
+		f.keys[name] = k.Name
+	}
+
+	return f
+}
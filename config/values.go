@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type (
+	// Section is part of the settings owned by a single binary, such as the
+	// project's consumer, so the packages behind it stay out of the others.
+	// It is read from the same sources as Config and its errors are
+	// reported with the rest.
+	Section struct {
+		Keys []Key
+		// Load parses the section's keys over its defaults and validates
+		// them, both through v.
+		Load func(v *Values)
+	}
+
+	// Values are the raw settings Load read and where each came from.
+	Values struct {
+		values  map[string]string
+		origins map[string]string
+		errs    []error
+	}
+)
+
+// Lookup returns the value of key, ok is false when it is unset or empty.
+func (v *Values) Lookup(key string) (string, bool) {
+	value, ok := v.values[key]
+	return value, ok && value != ""
+}
+
+// Origin is where key was read from: the env file's path, "environment",
+// the flag, or empty when it is unset.
+func (v *Values) Origin(key string) string {
+	return v.origins[key]
+}
+
+// Fail reports a value of key that doesn't parse, with where it came from.
+// The default is kept so the remaining keys are still checked.
+func (v *Values) Fail(key string, err error) {
+	v.errs = append(v.errs, fmt.Errorf("%s=%q (from %s): %w", key, v.values[key], v.origins[key], err))
+}
+
+// Section reports the validation error of a section, if any.
+func (v *Values) Section(name string, err error) {
+	if err != nil {
+		v.errs = append(v.errs, fmt.Errorf("%s: %w", name, err))
+	}
+}
+
+// String sets dst to key when it is set, even to an empty value.
+func (v *Values) String(key string, dst *string) {
+	if value, ok := v.values[key]; ok {
+		*dst = value
+	}
+}
+
+func (v *Values) Int(key string, dst *int) {
+	if value, ok := v.Lookup(key); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			v.Fail(key, errors.New("not an integer"))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (v *Values) Float(key string, dst *float64) {
+	if value, ok := v.Lookup(key); ok {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			v.Fail(key, errors.New("not a number"))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (v *Values) Duration(key string, dst *time.Duration) {
+	if value, ok := v.Lookup(key); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			v.Fail(key, errors.New("not a duration such as 30s or 5m"))
+			return
+		}
+		*dst = d
+	}
+}
+
+// Err joins every error reported so far.
+func (v *Values) Err() error {
+	return errors.Join(v.errs...)
+}
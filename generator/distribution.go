@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// DefaultAmount centres amounts around 4000 with a long tail of bigger invoices.
+const DefaultAmount = "lognormal:8.3,0.6"
+
+type (
+	Distribution interface {
+		Sample(rnd *rand.Rand) float64
+	}
+
+	Fixed struct {
+		Value float64
+	}
+
+	Uniform struct {
+		Min, Max float64
+	}
+
+	// LogNormal samples exp(N(Mu, Sigma)), Mu and Sigma are in log space.
+	LogNormal struct {
+		Mu, Sigma float64
+	}
+)
+
+func (d Fixed) Sample(rnd *rand.Rand) float64 {
+	return d.Value
+}
+
+func (d Uniform) Sample(rnd *rand.Rand) float64 {
+	return round(d.Min + rnd.Float64()*(d.Max-d.Min))
+}
+
+func (d LogNormal) Sample(rnd *rand.Rand) float64 {
+	return round(math.Exp(d.Mu + d.Sigma*rnd.NormFloat64()))
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// ParseDistribution reads "fixed:4000.10", "uniform:100,5000" or
+// "lognormal:8.3,0.6".
+func ParseDistribution(spec string) (Distribution, error) {
+	kind, args, _ := strings.Cut(strings.TrimSpace(spec), ":")
+
+	var params []float64
+	for _, arg := range strings.Split(args, ",") {
+		if strings.TrimSpace(arg) == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parameter %q in %q", arg, spec)
+		}
+		params = append(params, v)
+	}
+
+	switch strings.ToLower(kind) {
+	case "fixed":
+		if len(params) != 1 || params[0] <= 0 {
+			return nil, fmt.Errorf("fixed takes one positive amount, got %q", spec)
+		}
+		return Fixed{Value: round(params[0])}, nil
+	case "uniform":
+		if len(params) != 2 || params[0] <= 0 || params[0] > params[1] {
+			return nil, fmt.Errorf("uniform takes a positive min and a max not smaller than it, got %q", spec)
+		}
+		return Uniform{Min: params[0], Max: params[1]}, nil
+	case "lognormal":
+		if len(params) != 2 || params[1] < 0 {
+			return nil, fmt.Errorf("lognormal takes mu and a non negative sigma, got %q", spec)
+		}
+		return LogNormal{Mu: params[0], Sigma: params[1]}, nil
+	}
+
+	return nil, fmt.Errorf("unknown distribution %q, use fixed, uniform or lognormal", kind)
+}
@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"math/rand/v2"
+	"test/starkbank/money"
+	"test/starkbank/taxid"
+	"time"
+)
+
+// Generator produces fake but well formed invoice data. Two generators
+// built with the same seed and distribution return the same sequence.
+type Generator struct {
+	Seed    uint64
+	Amounts Distribution
+
+	rnd *rand.Rand
+}
+
+// New returns a generator for seed. A zero seed picks one from the clock,
+// it can be read back from Seed to reproduce the run.
+func New(seed uint64, amounts Distribution) *Generator {
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+
+	return &Generator{
+		Seed:    seed,
+		Amounts: amounts,
+		rnd:     rand.New(rand.NewPCG(seed, seed>>1|1)),
+	}
+}
+
+// Config is what a Generator is built from.
+type Config struct {
+	// Seed zero picks one from the clock.
+	Seed    uint64
+	Amounts Distribution
+}
+
+func DefaultConfig() Config {
+	amounts, _ := ParseDistribution(DefaultAmount)
+	return Config{Amounts: amounts}
+}
+
+// FromConfig is New with the seed and distribution of cfg.
+func FromConfig(cfg Config) *Generator {
+	return New(cfg.Seed, cfg.Amounts)
+}
+
+func (g *Generator) Name() string {
+	first := firstNames[g.rnd.IntN(len(firstNames))]
+	last := lastNames[g.rnd.IntN(len(lastNames))]
+
+	// about a third of brazilians carry two surnames
+	if g.rnd.IntN(3) == 0 {
+		return first + " " + lastNames[g.rnd.IntN(len(lastNames))] + " " + last
+	}
+
+	return first + " " + last
+}
+
+func (g *Generator) CPF() string {
+	base := g.digits(9)
+	verifiers, _ := taxid.CheckDigits(taxid.CPF, base)
+
+	return base + verifiers
+}
+
+func (g *Generator) CNPJ() string {
+	base := g.digits(8) + "0001"
+	verifiers, _ := taxid.CheckDigits(taxid.CNPJ, base)
+
+	return base + verifiers
+}
+
+// TaxId returns a CPF most of the time and a CNPJ otherwise, matching a
+// payer base made mostly of people.
+func (g *Generator) TaxId() string {
+	if g.rnd.IntN(5) == 0 {
+		return g.CNPJ()
+	}
+
+	return g.CPF()
+}
+
+// Amount samples the distribution, rounded to the nearest cent.
+func (g *Generator) Amount() money.Money {
+	return money.FromFloat(g.Amounts.Sample(g.rnd), money.HalfUp)
+}
+
+func (g *Generator) digits(size int) string {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte('0' + g.rnd.IntN(10))
+	}
+
+	// sequences like 00000000000 pass the checksum but are rejected by the
+	// revenue service
+	if allSame(b) {
+		b[size-1] = '0' + (b[size-1]-'0'+1)%10
+	}
+
+	return string(b)
+}
+
+func allSame(b []byte) bool {
+	for _, c := range b {
+		if c != b[0] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,18 @@
+package generator
+
+var firstNames = []string{
+	"Ana", "Antônio", "Beatriz", "Bruno", "Camila", "Carlos", "Clara", "Daniel",
+	"Eduarda", "Eduardo", "Fernanda", "Felipe", "Gabriela", "Gabriel", "Helena",
+	"Gustavo", "Isabela", "Henrique", "Júlia", "João", "Larissa", "José",
+	"Laura", "Lucas", "Letícia", "Luiz", "Manuela", "Marcos", "Maria", "Mateus",
+	"Mariana", "Miguel", "Natália", "Paulo", "Patrícia", "Pedro", "Rafaela",
+	"Rafael", "Sofia", "Rodrigo", "Tatiane", "Thiago", "Valentina", "Vinícius",
+}
+
+var lastNames = []string{
+	"Almeida", "Alves", "Araújo", "Barbosa", "Cardoso", "Carvalho", "Castro",
+	"Costa", "Dias", "Fernandes", "Ferreira", "Gomes", "Lima", "Lopes",
+	"Martins", "Melo", "Moreira", "Nascimento", "Oliveira", "Pereira", "Ribeiro",
+	"Rocha", "Rodrigues", "Santos", "Silva", "Soares", "Sousa", "Teixeira",
+	"Vieira",
+}
@@ -0,0 +1,29 @@
+package helpers
+
+import (
+	"context"
+	"time"
+)
+
+// DrainContext returns a context that outlives parent by timeout: it keeps
+// going after parent is cancelled and is only cancelled timeout later. It
+// lets in-flight work finish on shutdown without waiting forever.
+func DrainContext(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+
+	stop := context.AfterFunc(parent, func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			cancel()
+		case <-ctx.Done():
+		}
+	})
+
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync/atomic"
+)
+
+type Config struct {
+	Level slog.Level
+	// Format is "text" or "json".
+	Format string
+	// Output is "stdout", "stderr" or the path of a file.
+	Output string
+	// MaxSize is the size in bytes a log file is rotated at, zero never
+	// rotates. MaxFiles rotated files are kept next to it.
+	MaxSize  int64
+	MaxFiles int
+}
+
+// root is the handler every logger writes to, swapped by Setup. Loggers are
+// usually created in package vars before Setup runs, so they look it up on
+// every record instead of keeping the one they were created with.
+var root atomic.Pointer[slog.Handler]
+
+func init() {
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, nil)
+	root.Store(&h)
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Level:    slog.LevelInfo,
+		Format:   "text",
+		Output:   "stderr",
+		MaxSize:  10 << 20,
+		MaxFiles: 5,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Format != "text" && c.Format != "json" {
+		return fmt.Errorf("log format must be text or json, got %q", c.Format)
+	}
+	if c.Output == "" {
+		return fmt.Errorf("log output can't be empty")
+	}
+	if c.MaxSize < 0 || c.MaxFiles < 0 {
+		return fmt.Errorf("log rotation sizes can't be negative")
+	}
+
+	return nil
+}
+
+// Setup sends every logger to the output in cfg. The returned closer
+// releases the log file, if there is one.
+func Setup(cfg Config) (io.Closer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var out io.WriteCloser
+	switch cfg.Output {
+	case "stdout":
+		out = nopCloser{os.Stdout}
+	case "stderr":
+		out = nopCloser{os.Stderr}
+	default:
+		file, err := openRotating(cfg.Output, cfg.MaxSize, cfg.MaxFiles)
+		if err != nil {
+			return nil, err
+		}
+		out = file
+	}
+
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	var h slog.Handler = slog.NewTextHandler(out, opts)
+	if cfg.Format == "json" {
+		h = slog.NewJSONHandler(out, opts)
+	}
+	root.Store(&h)
+	slog.SetDefault(slog.New(handler{}))
+
+	return out, nil
+}
+
+// For returns the logger of a component, every record it writes carries a
+// component field.
+func For(component string) *slog.Logger {
+	return slog.New(handler{}).With("component", component)
+}
+
+type ctxKey struct{}
+
+// With returns a context whose records carry attrs, for the ids that follow
+// a request or message through every component it touches.
+func With(ctx context.Context, attrs ...any) context.Context {
+	previous, _ := ctx.Value(ctxKey{}).([]any)
+
+	return context.WithValue(ctx, ctxKey{}, append(previous[:len(previous):len(previous)], attrs...))
+}
+
+// handler forwards to the current root handler, replaying the attrs and
+// groups it was given.
+type handler struct {
+	wraps []func(slog.Handler) slog.Handler
+}
+
+func (h handler) current() slog.Handler {
+	current := *root.Load()
+	for _, wrap := range h.wraps {
+		current = wrap(current)
+	}
+
+	return current
+}
+
+func (h handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (*root.Load()).Enabled(ctx, level)
+}
+
+func (h handler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(ctxKey{}).([]any); ok {
+		r.Add(attrs...)
+	}
+
+	return h.current().Handle(ctx, r)
+}
+
+func (h handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h handler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h handler) with(wrap func(slog.Handler) slog.Handler) handler {
+	return handler{wraps: append(h.wraps[:len(h.wraps):len(h.wraps)], wrap)}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
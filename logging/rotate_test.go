@@ -0,0 +1,210 @@
+package logging
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// captureStderr points os.Stderr at a pipe until the returned func is called,
+// which gives back what was written to it.
+func captureStderr(t *testing.T) func() string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	t.Cleanup(func() { os.Stderr = stderr })
+
+	return func() string {
+		os.Stderr = stderr
+		w.Close()
+		data, _ := io.ReadAll(r)
+		return string(data)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  int64
+		maxFiles int
+		lines    []string
+		// want is the content of path, path.1, path.2 and path.3
+		want []string
+	}{
+		{
+			name:     "under the size",
+			maxSize:  100,
+			maxFiles: 2,
+			lines:    []string{"first\n", "second\n"},
+			want:     []string{"first\nsecond\n", "", "", ""},
+		},
+		{
+			name:     "rotates past the size",
+			maxSize:  10,
+			maxFiles: 2,
+			lines:    []string{"first\n", "second\n"},
+			want:     []string{"second\n", "first\n", "", ""},
+		},
+		{
+			name:     "older files shift up",
+			maxSize:  10,
+			maxFiles: 3,
+			lines:    []string{"first\n", "second\n", "third\n"},
+			want:     []string{"third\n", "second\n", "first\n", ""},
+		},
+		{
+			name:     "the oldest is pruned",
+			maxSize:  10,
+			maxFiles: 2,
+			lines:    []string{"first\n", "second\n", "third\n", "fourth\n"},
+			want:     []string{"fourth\n", "third\n", "second\n", ""},
+		},
+		{
+			name:     "no files kept",
+			maxSize:  10,
+			maxFiles: 0,
+			lines:    []string{"first\n", "second\n"},
+			want:     []string{"second\n", "", "", ""},
+		},
+		{
+			name:     "a line bigger than the size still goes in",
+			maxSize:  4,
+			maxFiles: 1,
+			lines:    []string{"first\n", "second\n"},
+			want:     []string{"second\n", "first\n", "", ""},
+		},
+		{
+			name:     "no size never rotates",
+			maxSize:  0,
+			maxFiles: 2,
+			lines:    []string{"first\n", "second\n"},
+			want:     []string{"first\nsecond\n", "", "", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs", "app.log")
+			r, err := openRotating(path, tt.maxSize, tt.maxFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			for _, line := range tt.lines {
+				if _, err := r.Write([]byte(line)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := []string{readLog(t, path), readLog(t, path+".1"), readLog(t, path+".2"), readLog(t, path+".3")}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("log files = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRotatingFileAppendsToExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := openRotating(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// the size left by the last run counts towards the rotation
+	r.Write([]byte("after\n"))
+	if got, want := readLog(t, path+".1"), "before\n"; got != want {
+		t.Fatalf("rotated file = %q, want %q", got, want)
+	}
+	if got, want := readLog(t, path), "after\n"; got != want {
+		t.Fatalf("log file = %q, want %q", got, want)
+	}
+}
+
+func TestRotatingFileRenameFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	// a directory that isn't empty can be neither removed nor renamed over
+	if err := os.MkdirAll(filepath.Join(path+".1", "taken"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := openRotating(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	stderr := captureStderr(t)
+	for _, line := range []string{"first\n", "a\n", "b\n", "c\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reported := stderr()
+
+	if got, want := readLog(t, path), "first\na\nb\nc\n"; got != want {
+		t.Fatalf("log file = %q, want %q", got, want)
+	}
+	// the failed rotation waits for another maxSize before trying again
+	if n := strings.Count(reported, "log rotation failed"); n != 1 {
+		t.Fatalf("reported %d failed rotations, want 1: %q", n, reported)
+	}
+}
+
+func TestRotatingFileFallsBackToStderr(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "app.log")
+	r, err := openRotating(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.Write([]byte("first\n"))
+
+	// with the directory gone the file can't be rotated or opened again
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := captureStderr(t)
+	n, err := r.Write([]byte("second\n"))
+	reported := stderr()
+
+	if err != nil || n != len("second\n") {
+		t.Fatalf("Write() = %d, %v, want the line written to stderr", n, err)
+	}
+	if !strings.Contains(reported, "log rotation failed") || !strings.HasSuffix(reported, "second\n") {
+		t.Fatalf("stderr = %q, want the failure and then the line", reported)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close() with no file open = %v", err)
+	}
+}
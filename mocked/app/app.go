@@ -0,0 +1,33 @@
+package app
+
+import (
+	"test/starkbank/config"
+	"test/starkbank/logging"
+	"test/starkbank/mocked/db"
+	"test/starkbank/money"
+	"time"
+)
+
+var logger = logging.For("api")
+
+// settings are what the handlers need from the config, set by Configure
+// before the server starts.
+var settings struct {
+	dbConn db.DbConn
+	// window is how long an idempotency key is remembered
+	window time.Duration
+	// lease is how long a key stays reserved without a stored response
+	lease time.Duration
+}
+
+func Configure(cfg config.Config) {
+	settings.dbConn = db.DbConn{
+		User:   cfg.DB.User,
+		Pass:   cfg.DB.Password,
+		Addr:   cfg.DB.Addr(),
+		DbName: cfg.DB.Name,
+	}
+	settings.window = cfg.Api.IdempotencyWindow
+	settings.lease = cfg.Api.IdempotencyLease
+	money.SetMode(cfg.Money)
+}
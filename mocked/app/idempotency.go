@@ -0,0 +1,126 @@
+package app
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"test/starkbank/mocked/app/model"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from an earlier request.
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// keyStore keeps the idempotency keys and the responses to replay.
+type keyStore interface {
+	Find(key string, window, lease time.Duration) (model.IdempotentResponse, bool, error)
+	Reserve(key, requestHash string, window, lease time.Duration) error
+	Complete(key string, statusCode int, response []byte) error
+	Release(key string) error
+}
+
+// sqlKeys keeps the keys in the idempotency_key table.
+type sqlKeys struct {
+	conn *sql.DB
+}
+
+func (k sqlKeys) Find(key string, window, lease time.Duration) (model.IdempotentResponse, bool, error) {
+	return model.FindIdempotencyKey(key, window, lease, k.conn)
+}
+
+func (k sqlKeys) Reserve(key, requestHash string, window, lease time.Duration) error {
+	return model.ReserveKey(key, requestHash, window, lease, k.conn)
+}
+
+func (k sqlKeys) Complete(key string, statusCode int, response []byte) error {
+	return model.CompleteKey(key, statusCode, response, k.conn)
+}
+
+func (k sqlKeys) Release(key string) error {
+	return model.ReleaseKey(key, k.conn)
+}
+
+func requestHash(request any) string {
+	body, _ := json.Marshal(request)
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotent runs create at most once per Idempotency-Key inside the window.
+// Repeated keys get the first response back; a key reused with a different
+// request, or while the first request is still running, is refused. Requests
+// without the header always run create.
+func idempotent(c echo.Context, keys keyStore, request any, create func() (int, any)) error {
+	key := c.Request().Header.Get(IdempotencyHeader)
+	if key == "" {
+		return c.JSON(create())
+	}
+	if len(key) > 255 {
+		return c.JSON(http.StatusBadRequest, "Idempotency-Key can't be longer than 255 characters")
+	}
+
+	hash := requestHash(request)
+	window, lease := settings.window, settings.lease
+
+	stored, ok, err := keys.Find(key, window, lease)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if ok {
+		return replay(c, stored, hash)
+	}
+
+	if err := keys.Reserve(key, hash, window, lease); errors.Is(err, model.ErrKeyInUse) {
+		return c.JSON(http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	status, resp := create()
+	if status >= 300 {
+		// only successes are replayed, a failed request can be tried again
+		release(keys, key)
+		return c.JSON(status, resp)
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		release(keys, key)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if err := keys.Complete(key, status, body); err != nil {
+		// the invoice exists already, answering 500 would only make the
+		// client retry into a 409. The reservation expires after the lease.
+		logger.Error("storing idempotent response", "key", key, "lease", lease, "err", err)
+	}
+
+	return c.JSONBlob(status, body)
+}
+
+// release drops the reservation of a failed request. If that fails too the
+// key stays reserved until the lease runs out.
+func release(keys keyStore, key string) {
+	if err := keys.Release(key); err != nil {
+		logger.Error("releasing idempotency key", "key", key, "lease", settings.lease, "err", err)
+	}
+}
+
+func replay(c echo.Context, stored model.IdempotentResponse, hash string) error {
+	if stored.RequestHash != hash {
+		return c.JSON(http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+	}
+	if stored.StatusCode == 0 {
+		return c.JSON(http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+	}
+
+	c.Response().Header().Set(ReplayedHeader, "true")
+	return c.JSONBlob(stored.StatusCode, stored.Response)
+}
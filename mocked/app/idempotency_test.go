@@ -0,0 +1,226 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"test/starkbank/mocked/app/model"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// memoryKeys keeps the keys like the idempotency_key table does, with now
+// standing in for the database clock.
+type memoryKeys struct {
+	mu   sync.Mutex
+	keys map[string]model.IdempotentResponse
+	now  time.Time
+	// completeErr makes Complete fail
+	completeErr error
+	// raced makes the next Reserve fail as if another request took the key
+	// between Find and Reserve
+	raced bool
+}
+
+func newMemoryKeys() *memoryKeys {
+	return &memoryKeys{keys: map[string]model.IdempotentResponse{}, now: time.Now()}
+}
+
+func (m *memoryKeys) live(stored model.IdempotentResponse, window, lease time.Duration) bool {
+	age := m.now.Sub(stored.CreatedAt)
+	return age < window && (stored.StatusCode != 0 || age < lease)
+}
+
+func (m *memoryKeys) advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+}
+
+func (m *memoryKeys) Find(key string, window, lease time.Duration) (model.IdempotentResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.keys[key]
+	if !ok || !m.live(stored, window, lease) {
+		return model.IdempotentResponse{}, false, nil
+	}
+	return stored, true, nil
+}
+
+func (m *memoryKeys) Reserve(key, requestHash string, window, lease time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.raced {
+		m.raced = false
+		return model.ErrKeyInUse
+	}
+	if stored, ok := m.keys[key]; ok && m.live(stored, window, lease) {
+		return model.ErrKeyInUse
+	}
+	m.keys[key] = model.IdempotentResponse{Key: key, RequestHash: requestHash, CreatedAt: m.now}
+	return nil
+}
+
+func (m *memoryKeys) Complete(key string, statusCode int, response []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.completeErr != nil {
+		return m.completeErr
+	}
+	stored := m.keys[key]
+	stored.StatusCode, stored.Response = statusCode, response
+	m.keys[key] = stored
+	return nil
+}
+
+func (m *memoryKeys) Release(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, key)
+	return nil
+}
+
+func useWindow(t *testing.T, window, lease time.Duration) {
+	t.Helper()
+
+	previous := settings
+	settings.window, settings.lease = window, lease
+	t.Cleanup(func() { settings = previous })
+}
+
+// call runs one request with key through idempotent and records the response.
+func call(keys keyStore, key string, request any, create func() (int, any)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/invoice", nil)
+	if key != "" {
+		req.Header.Set(IdempotencyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	idempotent(echo.New().NewContext(req, rec), keys, request, create)
+	return rec
+}
+
+// counting returns a create that answers with status and counts its runs.
+func counting(status int, runs *int) func() (int, any) {
+	return func() (int, any) {
+		*runs++
+		return status, map[string]int{"run": *runs}
+	}
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	useWindow(t, time.Hour, time.Minute)
+	keys := newMemoryKeys()
+	runs := 0
+
+	first := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs))
+	second := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs))
+
+	if runs != 1 {
+		t.Fatalf("create ran %d times, want 1", runs)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Fatalf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(ReplayedHeader) != "true" || first.Header().Get(ReplayedHeader) != "" {
+		t.Fatalf("%s header = %q on the replay and %q on the first response", ReplayedHeader, second.Header().Get(ReplayedHeader), first.Header().Get(ReplayedHeader))
+	}
+
+	if rec := call(keys, "key-1", "another invoice", counting(http.StatusCreated, &runs)); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("key reused with a different request = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	// past the window the key is forgotten
+	keys.advance(time.Hour)
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs)); rec.Code != http.StatusCreated || runs != 2 {
+		t.Fatalf("after the window = %d with %d runs, want %d with 2", rec.Code, runs, http.StatusCreated)
+	}
+}
+
+func TestIdempotentConcurrent(t *testing.T) {
+	useWindow(t, time.Hour, time.Minute)
+	keys := newMemoryKeys()
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- call(keys, "key-1", "invoice", func() (int, any) {
+			close(started)
+			<-finish
+			return http.StatusCreated, "created"
+		})
+	}()
+	<-started
+
+	runs := 0
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs)); rec.Code != http.StatusConflict || runs != 0 {
+		t.Fatalf("second request while the first runs = %d with %d runs, want %d with 0", rec.Code, runs, http.StatusConflict)
+	}
+
+	close(finish)
+	if rec := <-done; rec.Code != http.StatusCreated {
+		t.Fatalf("first request = %d, want %d", rec.Code, http.StatusCreated)
+	}
+
+	// both passed Find, but the other request reserved the key first
+	keys.raced = true
+	if rec := call(keys, "key-2", "invoice", counting(http.StatusCreated, &runs)); rec.Code != http.StatusConflict || runs != 0 {
+		t.Fatalf("request losing the reservation = %d with %d runs, want %d with 0", rec.Code, runs, http.StatusConflict)
+	}
+}
+
+func TestIdempotentReleasesFailures(t *testing.T) {
+	useWindow(t, time.Hour, time.Minute)
+	keys := newMemoryKeys()
+	runs := 0
+
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusBadRequest, &runs)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("failed request = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs)); rec.Code != http.StatusCreated || runs != 2 {
+		t.Fatalf("retry after a failure = %d with %d runs, want %d with 2", rec.Code, runs, http.StatusCreated)
+	}
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs)); rec.Header().Get(ReplayedHeader) != "true" || runs != 2 {
+		t.Fatalf("request after the retry ran create again, %d runs", runs)
+	}
+
+	// a response that can't be encoded also frees the key
+	if rec := call(keys, "key-2", "invoice", func() (int, any) { return http.StatusCreated, func() {} }); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("unencodable response = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if _, ok, _ := keys.Find("key-2", time.Hour, time.Minute); ok {
+		t.Fatal("key is still reserved after the response failed to encode")
+	}
+}
+
+func TestIdempotentLeaseExpires(t *testing.T) {
+	useWindow(t, time.Hour, time.Minute)
+	keys := newMemoryKeys()
+	keys.completeErr = errors.New("connection lost")
+	runs := 0
+
+	// the response is sent but never stored, so the key stays reserved
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs)); rec.Code != http.StatusCreated {
+		t.Fatalf("first request = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	keys.completeErr = nil
+
+	keys.advance(time.Minute - time.Second)
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs)); rec.Code != http.StatusConflict || runs != 1 {
+		t.Fatalf("inside the lease = %d with %d runs, want %d with 1", rec.Code, runs, http.StatusConflict)
+	}
+
+	keys.advance(time.Second)
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs)); rec.Code != http.StatusCreated || runs != 2 {
+		t.Fatalf("after the lease = %d with %d runs, want %d with 2", rec.Code, runs, http.StatusCreated)
+	}
+	if rec := call(keys, "key-1", "invoice", counting(http.StatusCreated, &runs)); rec.Header().Get(ReplayedHeader) != "true" || runs != 2 {
+		t.Fatalf("request after the lease ran create again, %d runs", runs)
+	}
+}
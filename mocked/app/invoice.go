@@ -1,25 +1,20 @@
 package app
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
-	"test/starkbank/helpers"
 	"test/starkbank/mocked/app/model"
 	"test/starkbank/mocked/db"
+	"test/starkbank/taxid"
 
 	"github.com/labstack/echo/v4"
 )
 
-var dbConn = db.DbConn{
-	User:   helpers.Env("DB_USER"),
-	Pass:   helpers.Env("DB_PASSWORD"),
-	Addr:   helpers.Env("DB_HOST") + ":" + helpers.Env("DB_PORT"),
-	DbName: helpers.Env("DB_NAME"),
-}
-
 func CreateInvoice(c echo.Context) error {
-	conn, err := db.Connect(dbConn)
+	conn, err := db.Connect(settings.dbConn)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
@@ -28,33 +23,44 @@ func CreateInvoice(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, bindErr.Error())
 	}
 
-	if i.Name == "Renarin Kholin12" || i.Name == "Renarin Kholin2" {
-		ficErr := fmt.Errorf("error for testing")
-		return c.JSON(http.StatusBadRequest, ficErr.Error())
-	}
-
-	resp, err := model.StoreInvoice(*i, conn)
+	taxId, err := taxid.Normalize(i.TaxId)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-	return c.JSON(http.StatusCreated, resp)
+	i.TaxId = taxId
+
+	return idempotent(c, sqlKeys{conn}, i, func() (int, any) {
+		if i.Name == "Renarin Kholin12" || i.Name == "Renarin Kholin2" {
+			ficErr := fmt.Errorf("error for testing")
+			return http.StatusBadRequest, ficErr.Error()
+		}
+
+		resp, err := model.StoreInvoice(*i, conn)
+		if err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		return http.StatusCreated, resp
+	})
 }
 
 func ConsultInvoice(c echo.Context) error {
-	conn, err := db.Connect(dbConn)
+	conn, err := db.Connect(settings.dbConn)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		fmt.Println("error converting invoice id to int: ", err.Error())
-		return c.JSON(http.StatusInternalServerError, err)
+		logger.Warn("invoice id is not an integer", "id", c.Param("id"), "err", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
-	
+
 	resp, err := model.InvoiceById(int64(id), conn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return c.JSON(http.StatusInternalServerError, err.Error())
 	}
 
 	return c.JSON(http.StatusOK, resp)
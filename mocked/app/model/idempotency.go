@@ -0,0 +1,74 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrKeyInUse is returned by ReserveKey when another request already holds
+// the key inside the window.
+var ErrKeyInUse = errors.New("idempotency key already in use")
+
+type IdempotentResponse struct {
+	Key         string
+	RequestHash string
+	// StatusCode is zero while the first request with the key is still
+	// being processed.
+	StatusCode int
+	Response   []byte
+	CreatedAt  time.Time
+}
+
+// FindIdempotencyKey returns the response stored for key, ignoring entries
+// older than window and reservations without a response older than lease.
+// ok is false when there is none. Both are applied by the database so its
+// clock is the only one that matters.
+func FindIdempotencyKey(key string, window, lease time.Duration, db *sql.DB) (resp IdempotentResponse, ok bool, err error) {
+	var status sql.NullInt64
+	row := db.QueryRow("SELECT idem_key, request_hash, status_code, response, created_at FROM idempotency_key WHERE idem_key = ? AND created_at >= NOW() - INTERVAL ? SECOND AND (status_code IS NOT NULL OR created_at >= NOW() - INTERVAL ? SECOND)", key, int64(window/time.Second), int64(lease/time.Second))
+	err = row.Scan(&resp.Key, &resp.RequestHash, &status, &resp.Response, &resp.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return IdempotentResponse{}, false, nil
+	}
+	if err != nil {
+		return IdempotentResponse{}, false, err
+	}
+	resp.StatusCode = int(status.Int64)
+
+	return resp, true, nil
+}
+
+// ReserveKey claims key for a request before it is processed, so two
+// concurrent requests with the same key can't both go through. Entries older
+// than window are expired and replaced, and so are reservations older than
+// lease that never stored a response, left by a request that crashed or
+// failed to complete the key.
+func ReserveKey(key string, requestHash string, window, lease time.Duration, db *sql.DB) error {
+	if _, err := db.Exec("DELETE FROM idempotency_key WHERE idem_key = ? AND (created_at < NOW() - INTERVAL ? SECOND OR status_code IS NULL AND created_at < NOW() - INTERVAL ? SECOND)", key, int64(window/time.Second), int64(lease/time.Second)); err != nil {
+		return err
+	}
+
+	_, err := db.Exec("INSERT INTO idempotency_key (idem_key, request_hash) VALUES (?, ?)", key, requestHash)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return ErrKeyInUse
+	}
+
+	return err
+}
+
+// CompleteKey stores the response to be replayed for key.
+func CompleteKey(key string, statusCode int, response []byte, db *sql.DB) error {
+	_, err := db.Exec("UPDATE idempotency_key SET status_code = ?, response = ? WHERE idem_key = ?", statusCode, response, key)
+	return err
+}
+
+// ReleaseKey drops a reservation whose request failed, so the key can be
+// retried.
+func ReleaseKey(key string, db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM idempotency_key WHERE idem_key = ?", key)
+	return err
+}
@@ -4,49 +4,69 @@ import (
 	"database/sql"
 	"fmt"
 	"math/rand"
+	"test/starkbank/money"
 	"time"
 )
 
 type (
+	// Invoice is stored with Amount and Fee in cents. Fine is a percentage
+	// of Amount charged once overdue and Interest a percentage a month, both
+	// rates rather than amounts.
 	Invoice struct {
-		Amount     float64
+		Amount     money.Money
 		TaxId      string
 		Due        time.Time
 		Expiration int64
 		Fine       float64
 		Interest   float64
-		Fee        float64
+		Fee        money.Money
 		Status     string
 	}
 
 	InviceResp struct {
 		ID         int64
-		Amount     float64
+		Amount     money.Money
 		TaxId      string
 		Due        time.Time
 		Expiration int64
 		Fine       float64
 		Interest   float64
-		Fee        float64
+		Fee        money.Money
 		Status     string
 		CreatedAt  time.Time
 		UpdatedAt  time.Time
 	}
 
 	InvoiceRequest struct {
-		Amount float64 `json:"amount" xml:"amount" form:"amount" query:"amount"`
-		Name   string  `json:"name" xml:"name" form:"name" query:"name"`
-		TaxId  string  `json:"tax_id" xml:"tax_id" form:"tax_id" query:"tax_id"`
+		Amount money.Money `json:"amount" xml:"amount" form:"amount" query:"amount"`
+		Name   string      `json:"name" xml:"name" form:"name" query:"name"`
+		TaxId  string      `json:"tax_id" xml:"tax_id" form:"tax_id" query:"tax_id"`
 	}
 )
 
-// query example
+// invoiceFee is charged on every invoice.
+const invoiceFee = money.Money(340)
+
+// StatusPayed is the status of an invoice that was paid and can be
+// transferred out.
+const StatusPayed = "PAYED"
+
+// paymentDelay is how long after it is created a CREATED invoice gets
+// paid, standing in for the payer.
+const paymentDelay = 10 * time.Second
+
+// InvoiceById returns the invoice as it is now, paying it first if its
+// paymentDelay has passed.
 func InvoiceById(id int64, db *sql.DB) (InviceResp, error) {
+	if err := payDue(id, db); err != nil {
+		return InviceResp{}, err
+	}
+
 	row := db.QueryRow("SELECT * FROM invoice WHERE id = ?", id)
 
 	invoiceResp := InviceResp{}
 	if err := row.Scan(&invoiceResp.ID, &invoiceResp.Amount, &invoiceResp.TaxId, &invoiceResp.Due, &invoiceResp.Expiration, &invoiceResp.Fine, &invoiceResp.Interest, &invoiceResp.Fee, &invoiceResp.Status, &invoiceResp.CreatedAt, &invoiceResp.UpdatedAt); err != nil {
-		return InviceResp{}, fmt.Errorf("no invoice with this Id %q: %v", id, err)
+		return InviceResp{}, fmt.Errorf("no invoice with this Id %d: %w", id, err)
 	}
 	invoiceResp.Status = getStatus(invoiceResp.Status)
 
@@ -61,7 +81,7 @@ func StoreInvoice(request InvoiceRequest, db *sql.DB) (InviceResp, error) {
 		Expiration: 4,
 		Fine:       0,
 		Interest:   2,
-		Fee:        3.4,
+		Fee:        invoiceFee,
 		Status:     randomStatus(),
 	}
 
@@ -85,6 +105,18 @@ func StoreInvoice(request InvoiceRequest, db *sql.DB) (InviceResp, error) {
 	return invoiceResp, nil
 }
 
+// payDue marks a CREATED invoice PAYED once paymentDelay has passed since
+// it was created. Invoices are paid when they are read rather than by a
+// timer, so nothing has to run between requests.
+func payDue(id int64, db *sql.DB) error {
+	_, err := db.Exec("UPDATE invoice SET status = 'P' WHERE id = ? AND status = 'C' AND created_at <= NOW() - INTERVAL ? SECOND", id, int(paymentDelay.Seconds()))
+	if err != nil {
+		return fmt.Errorf("error paying invoice %d: %w", id, err)
+	}
+
+	return nil
+}
+
 func randomStatus() string {
 	status := make(map[int]string)
 	status[1] = "P"
@@ -92,7 +124,7 @@ func randomStatus() string {
 
 	min, max := 1, 2
 
-	return status[min+rand.Intn(max-min)]
+	return status[min+rand.Intn(max-min+1)]
 }
 
 func getStatus(char string) string {
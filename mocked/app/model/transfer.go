@@ -0,0 +1,97 @@
+package model
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"test/starkbank/money"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ErrTransferExists is returned by StoreTransfer when the invoice was
+// already paid out, an invoice has at most one transfer.
+var ErrTransferExists = errors.New("invoice already has a transfer")
+
+type (
+	TransferRequest struct {
+		InvoiceId     int64       `json:"invoice_id" xml:"invoice_id" form:"invoice_id" query:"invoice_id"`
+		Amount        money.Money `json:"amount" xml:"amount" form:"amount" query:"amount"`
+		Name          string      `json:"name" xml:"name" form:"name" query:"name"`
+		TaxId         string      `json:"tax_id" xml:"tax_id" form:"tax_id" query:"tax_id"`
+		BankCode      string      `json:"bank_code" xml:"bank_code" form:"bank_code" query:"bank_code"`
+		BranchCode    string      `json:"branch_code" xml:"branch_code" form:"branch_code" query:"branch_code"`
+		AccountNumber string      `json:"account_number" xml:"account_number" form:"account_number" query:"account_number"`
+	}
+
+	TransferResp struct {
+		ID            int64
+		InvoiceId     int64
+		Amount        money.Money
+		Name          string
+		TaxId         string
+		BankCode      string
+		BranchCode    string
+		AccountNumber string
+		Status        string
+		CreatedAt     time.Time
+		UpdatedAt     time.Time
+	}
+)
+
+const transferColumns = "id, invoice_id, amount, name, tax_id, bank_code, branch_code, account_number, status, created_at, updated_at"
+
+// StoreTransfer pays out request. transfer.invoice_id is unique, so a second
+// transfer for the same invoice fails with ErrTransferExists even when it
+// comes without an idempotency key or after the key expired.
+func StoreTransfer(request TransferRequest, db *sql.DB) (TransferResp, error) {
+	result, err := db.Exec("INSERT INTO transfer (invoice_id, amount, name, tax_id, bank_code, branch_code, account_number, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", request.InvoiceId, request.Amount, request.Name, request.TaxId, request.BankCode, request.BranchCode, request.AccountNumber, "S")
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		return TransferResp{}, fmt.Errorf("%w: invoice %d", ErrTransferExists, request.InvoiceId)
+	}
+	if err != nil {
+		return TransferResp{}, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return TransferResp{}, err
+	}
+
+	transferResp, err := scanTransfer(db.QueryRow("SELECT "+transferColumns+" FROM transfer WHERE id = ?", id))
+	if err != nil {
+		return TransferResp{}, fmt.Errorf("error getting Transfer with this Id %d: %v", id, err)
+	}
+
+	return transferResp, nil
+}
+
+// FindTransferByInvoice returns the transfer that paid out an invoice.
+func FindTransferByInvoice(invoiceId int64, db *sql.DB) (TransferResp, error) {
+	transferResp, err := scanTransfer(db.QueryRow("SELECT "+transferColumns+" FROM transfer WHERE invoice_id = ?", invoiceId))
+	if err != nil {
+		return TransferResp{}, fmt.Errorf("error getting Transfer of invoice %d: %v", invoiceId, err)
+	}
+
+	return transferResp, nil
+}
+
+func scanTransfer(row *sql.Row) (TransferResp, error) {
+	transferResp := TransferResp{}
+	if err := row.Scan(&transferResp.ID, &transferResp.InvoiceId, &transferResp.Amount, &transferResp.Name, &transferResp.TaxId, &transferResp.BankCode, &transferResp.BranchCode, &transferResp.AccountNumber, &transferResp.Status, &transferResp.CreatedAt, &transferResp.UpdatedAt); err != nil {
+		return TransferResp{}, err
+	}
+	transferResp.Status = getTransferStatus(transferResp.Status)
+
+	return transferResp, nil
+}
+
+func getTransferStatus(char string) string {
+	status := make(map[string]string)
+	status["S"] = "SUCCESS"
+	status["F"] = "FAILED"
+
+	return status[char]
+}
@@ -1,23 +1,71 @@
 package app
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
+	"test/starkbank/mocked/app/model"
+	"test/starkbank/mocked/db"
+	"test/starkbank/taxid"
 
 	"github.com/labstack/echo/v4"
 )
 
-type Transfer struct {
-	Amount int64 `json:"amount" xml:"amount" form:"amount" query:"amount"`
-	Name   string	`json:"name" xml:"name" form:"name" query:"name"`
-	TaxId  string  `json:"tax_id" xml:"tax_id" form:"tax_id" query:"tax_id"`
-}
-
+// MakeTransfer pays out a PAYED invoice, amount minus fee, to the account
+// in the request. With an Idempotency-Key a repeated request gets the
+// first transfer back instead of a second one, and an invoice that was
+// already paid out gets its existing transfer back whatever the key.
 func MakeTransfer(c echo.Context) error {
-	i := Transfer{
-		Name:  "Renarin",
-		Amount: 40000,
-		TaxId: "155.555.555-47",
+	conn, err := db.Connect(settings.dbConn)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	t := new(model.TransferRequest)
+	if bindErr := c.Bind(t); bindErr != nil {
+		return c.JSON(http.StatusBadRequest, bindErr.Error())
+	}
+
+	taxId, err := taxid.Normalize(t.TaxId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	t.TaxId = taxId
+
+	if t.Amount <= 0 {
+		return c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid transfer amount %s", t.Amount))
 	}
-	return c.JSON(http.StatusCreated, i)
+	if t.BankCode == "" || t.BranchCode == "" || t.AccountNumber == "" {
+		return c.JSON(http.StatusBadRequest, "bank_code, branch_code and account_number are required")
+	}
+
+	invoice, err := model.InvoiceById(t.InvoiceId, conn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if invoice.Status != model.StatusPayed {
+		return c.JSON(http.StatusConflict, fmt.Sprintf("invoice %d is %s, only PAYED invoices are transferred", invoice.ID, invoice.Status))
+	}
+	if due := invoice.Amount.Sub(invoice.Fee); t.Amount != due {
+		return c.JSON(http.StatusUnprocessableEntity, fmt.Sprintf("transfer amount %s must be the invoice amount minus its fee, %s", t.Amount, due))
+	}
+
+	return idempotent(c, sqlKeys{conn}, t, func() (int, any) {
+		resp, err := model.StoreTransfer(*t, conn)
+		if errors.Is(err, model.ErrTransferExists) {
+			resp, err = model.FindTransferByInvoice(t.InvoiceId, conn)
+			if err != nil {
+				return http.StatusInternalServerError, err.Error()
+			}
+			c.Response().Header().Set(ReplayedHeader, "true")
+			return http.StatusCreated, resp
+		}
+		if err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		return http.StatusCreated, resp
+	})
 }
- 
\ No newline at end of file
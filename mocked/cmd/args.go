@@ -1,56 +1,60 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"os"
-	"test/starkbank/helpers"
+	"strconv"
+	"test/starkbank/config"
+	"test/starkbank/generator"
 	"test/starkbank/mocked/cmd/common"
 	"test/starkbank/mocked/cmd/migration"
 	"test/starkbank/mocked/cmd/parsers"
+	"test/starkbank/mocked/cmd/seeder"
 	"test/starkbank/mocked/db"
 )
 
+func command(cmd string, cfg config.Config) {
+	conn := db.DbConn{
+		User:   cfg.DB.User,
+		Pass:   cfg.DB.Password,
+		Addr:   cfg.DB.Addr(),
+		DbName: cfg.DB.Name,
+	}
 
-var conn = db.DbConn{
-	User: helpers.Env("DB_USER"),
-	Pass: helpers.Env("DB_PASSWORD"),
-	Addr: helpers.Env("DB_HOST") + ":" + helpers.Env("DB_PORT"),
-	DbName: helpers.Env("DB_NAME"),
-}
-
-func command(cmd string) {
 	switch cmd {
 	case "create:migration":
 		migrationCmd()
 	case "create:controller":
 		controllerCmd()
 	case "migrate":
-		migrateCmd()
+		migrateCmd(conn)
 	case "migrate:rollback":
-		rollbackCmd()
+		rollbackCmd(conn)
+	case "db:seed":
+		seedCmd(conn, cfg.Generator)
 	default:
 		errorC()
 	}
 }
 
 func migrationCmd() {
-	if len(os.Args) < 3 {
+	if flag.NArg() < 2 {
 		nameMissing()
 		return
 	}
 
-	name := os.Args[2]
+	name := flag.Arg(1)
 
 	parsers.GenerateMigrationFile(name)
 }
 
 func controllerCmd() {
-	if len(os.Args) < 3 {
+	if flag.NArg() < 2 {
 		nameMissing()
 		return
 	}
 
-	name := os.Args[2]
+	name := flag.Arg(1)
 
 	parsers.GenerateControllerFile(name)
 }
@@ -60,7 +64,7 @@ func nameMissing() {
 	fmt.Println(common.Yellow, " Use ./gomd command <name>", common.Reset)
 }
 
-func migrateCmd() {
+func migrateCmd(conn db.DbConn) {
 	db, err := db.Connect(conn)
 	if err != nil {
 		fmt.Println(common.Red, "Error connecting to database:", err.Error(), common.Reset)
@@ -73,7 +77,7 @@ func migrateCmd() {
 	}
 }
 
-func rollbackCmd() {
+func rollbackCmd(conn db.DbConn) {
 	db, err := db.Connect(conn)
 	if err != nil {
 		fmt.Println(common.Red, "Error connecting to database:", err.Error(), common.Reset)
@@ -85,3 +89,37 @@ func rollbackCmd() {
 		return
 	}
 }
+
+func seedCmd(conn db.DbConn, gen generator.Config) {
+	if flag.NArg() < 2 {
+		fmt.Println(common.Red, "Amount of invoices not specified.", common.Reset)
+		fmt.Println(common.Yellow, " Use ./gomd db:seed <count> [seed]", common.Reset)
+		return
+	}
+
+	count, err := strconv.Atoi(flag.Arg(1))
+	if err != nil || count < 1 {
+		fmt.Println(common.Red, "Invalid amount of invoices:", flag.Arg(1), common.Reset)
+		return
+	}
+
+	seed := gen.Seed
+	if flag.NArg() > 2 {
+		seed, err = strconv.ParseUint(flag.Arg(2), 10, 64)
+		if err != nil {
+			fmt.Println(common.Red, "Invalid seed:", flag.Arg(2), common.Reset)
+			return
+		}
+	}
+
+	db, err := db.Connect(conn)
+	if err != nil {
+		fmt.Println(common.Red, "Error connecting to database:", err.Error(), common.Reset)
+		return
+	}
+	err = seeder.Invoices(db, generator.New(seed, gen.Amounts), count)
+	if err != nil {
+		fmt.Println(common.Red, "Error seeding:", err.Error(), common.Reset)
+		return
+	}
+}
@@ -4,25 +4,34 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"test/starkbank/config"
 	"test/starkbank/mocked/cmd/common"
 )
 
 func main() {
 	var help bool
 	flag.BoolVar(&help, "h", false, "Show help")
+	flags := config.RegisterFlags(flag.CommandLine)
 	flag.Parse()
 
-	if len(os.Args) == 1 {
-		errorC()
+	if help || flag.Arg(0) == "help" {
+		helpC()
 		return
 	}
 
-	if help || (len(os.Args) > 1 && os.Args[1] == "help") {
-		helpC()
+	if flag.NArg() == 0 {
+		errorC()
 		return
 	}
 
-	command(os.Args[1])
+	cfg, err := config.Load(flags)
+	if err != nil {
+		fmt.Println(common.Red, "Invalid configuration:", common.Reset)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	command(flag.Arg(0), cfg)
 }
 
 func errorC() {
@@ -35,9 +44,11 @@ func helpC() {
 	fmt.Println("  create:migration")
 	fmt.Println("  create:controller")
 	fmt.Println("  migrate")
+	fmt.Println("  db:seed")
 	fmt.Println("     Usage:")
 	fmt.Println("       ./gomd create:migration -name <name>")
 	fmt.Println("       ./gomd create:controller -name <name>")
 	fmt.Println("       ./gomd migrate")
+	fmt.Println("       ./gomd db:seed <count> [seed]")
 	fmt.Println(common.Reset)
 }
@@ -79,9 +79,11 @@ func (e *DB) Migrate() error {
 			return fmt.Errorf(common.Red+"No up sql found in migration %s"+common.Reset, file.Name)
 		}
 
-		_, err = tx.Exec(string(up))
-		if err != nil {
-			return fmt.Errorf("error executing migration %s: %w", file.Name, err)
+		for _, statement := range splitStatements(up) {
+			_, err = tx.Exec(statement)
+			if err != nil {
+				return fmt.Errorf("error executing migration %s: %w", file.Name, err)
+			}
 		}
 		//Record migration
 		_, err = tx.Exec("insert into migrations (batch, name, checksum) values (?, ?, ?)", batch, file.Name, checksum)
@@ -142,9 +144,11 @@ func (e *DB) Rollback() error {
 			return fmt.Errorf(common.Red+"No down sql found in migration %s"+common.Reset, file.Name)
 		}
 
-		_, err = tx.Exec(string(down))
-		if err != nil {
-			return fmt.Errorf("error rolling back migration %s: %w", file.Name, err)
+		for _, statement := range splitStatements(down) {
+			_, err = tx.Exec(statement)
+			if err != nil {
+				return fmt.Errorf("error rolling back migration %s: %w", file.Name, err)
+			}
 		}
 		//Delete migration record
 		_, err = tx.Exec("delete from migrations where name = ?", file.Name)
@@ -127,6 +127,25 @@ func getDown(content string) string {
 	return strings.TrimSpace(strings.Join(block, "\n"))
 }
 
+// splitStatements splits a block on the lines ending in ";", the driver
+// runs one statement per Exec.
+func splitStatements(block string) []string {
+	var statements []string
+	var current []string
+	for _, line := range strings.Split(block, "\n") {
+		current = append(current, line)
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			statements = append(statements, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	if rest := strings.TrimSpace(strings.Join(current, "\n")); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
+
 func getLastBatch(db *sql.DB) (int, error) {
 	var batch int
 	err := db.QueryRow("select coalesce(max(batch), 0) from migrations").Scan(&batch)
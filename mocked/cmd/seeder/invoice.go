@@ -0,0 +1,29 @@
+package seeder
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"test/starkbank/generator"
+	"test/starkbank/mocked/app/model"
+	"test/starkbank/mocked/cmd/common"
+)
+
+func Invoices(db *sql.DB, gen *generator.Generator, count int) error {
+	log.Printf(common.Yellow+"Seeding %d invoices with seed %d"+common.Reset, count, gen.Seed)
+
+	for i := 0; i < count; i++ {
+		request := model.InvoiceRequest{
+			Amount: gen.Amount(),
+			Name:   gen.Name(),
+			TaxId:  gen.TaxId(),
+		}
+
+		if _, err := model.StoreInvoice(request, db); err != nil {
+			return fmt.Errorf("error seeding invoice %d: %w", i+1, err)
+		}
+	}
+
+	log.Printf(common.Green+"Successfully seeded %d invoices"+common.Reset, count)
+	return nil
+}
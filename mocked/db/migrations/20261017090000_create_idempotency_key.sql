@@ -0,0 +1,10 @@
+-- +migrate Up
+CREATE TABLE idempotency_key (
+		idem_key VARCHAR(255) PRIMARY KEY,
+        request_hash CHAR(64) NOT NULL,
+        status_code INT,
+        response TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+-- +migrate Down
+DROP TABLE idempotency_key;
@@ -0,0 +1,16 @@
+-- +migrate Up
+CREATE TABLE transfer (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+        invoice_id BIGINT NOT NULL,
+        amount FLOAT,
+        name VARCHAR(255),
+        tax_id VARCHAR(16),
+        bank_code VARCHAR(8),
+        branch_code VARCHAR(8),
+        account_number VARCHAR(32),
+        status CHAR,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	);
+-- +migrate Down
+DROP TABLE transfer;
@@ -0,0 +1,73 @@
+-- +migrate Up
+-- amounts and fees become integer cents; fine and interest are rates and
+-- stay FLOAT. MySQL commits every ALTER on its own, so the cents go into new
+-- columns that then replace the old ones, and every step checks the columns
+-- first: a run cut short anywhere can be run again without scaling twice.
+-- The DECIMAL cast rounds the FLOAT noise away before scaling.
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount_cents') IS NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount') = 'float',
+    'ALTER TABLE invoice ADD COLUMN amount_cents BIGINT AFTER amount, ADD COLUMN fee_cents BIGINT AFTER fee', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount_cents') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount') = 'float',
+    'UPDATE invoice SET amount_cents = CAST(amount AS DECIMAL(20,2)) * 100, fee_cents = CAST(fee AS DECIMAL(20,2)) * 100', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount_cents') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount') = 'float',
+    'ALTER TABLE invoice DROP COLUMN amount, DROP COLUMN fee', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount_cents') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount') IS NULL,
+    'ALTER TABLE invoice CHANGE amount_cents amount BIGINT, CHANGE fee_cents fee BIGINT', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount_cents') IS NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount') = 'float',
+    'ALTER TABLE transfer ADD COLUMN amount_cents BIGINT AFTER amount', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount_cents') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount') = 'float',
+    'UPDATE transfer SET amount_cents = CAST(amount AS DECIMAL(20,2)) * 100', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount_cents') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount') = 'float',
+    'ALTER TABLE transfer DROP COLUMN amount', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount_cents') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount') IS NULL,
+    'ALTER TABLE transfer CHANGE amount_cents amount BIGINT', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+DEALLOCATE PREPARE step;
+-- +migrate Down
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount_reais') IS NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount') = 'bigint',
+    'ALTER TABLE transfer ADD COLUMN amount_reais FLOAT AFTER amount', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount_reais') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount') = 'bigint',
+    'UPDATE transfer SET amount_reais = amount / 100', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount_reais') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount') = 'bigint',
+    'ALTER TABLE transfer DROP COLUMN amount', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount_reais') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'transfer' AND COLUMN_NAME = 'amount') IS NULL,
+    'ALTER TABLE transfer CHANGE amount_reais amount FLOAT', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount_reais') IS NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount') = 'bigint',
+    'ALTER TABLE invoice ADD COLUMN amount_reais FLOAT AFTER amount, ADD COLUMN fee_reais FLOAT AFTER fee', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount_reais') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount') = 'bigint',
+    'UPDATE invoice SET amount_reais = amount / 100, fee_reais = fee / 100', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount_reais') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount') = 'bigint',
+    'ALTER TABLE invoice DROP COLUMN amount, DROP COLUMN fee', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+SET @step = IF((SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount_reais') IS NOT NULL AND (SELECT DATA_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'invoice' AND COLUMN_NAME = 'amount') IS NULL,
+    'ALTER TABLE invoice CHANGE amount_reais amount FLOAT, CHANGE fee_reais fee FLOAT', 'DO 0');
+PREPARE step FROM @step;
+EXECUTE step;
+DEALLOCATE PREPARE step;
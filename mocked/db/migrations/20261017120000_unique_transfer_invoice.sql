@@ -0,0 +1,6 @@
+-- +migrate Up
+-- an invoice is paid out at most once; duplicates left by earlier runs must
+-- be removed by hand before this applies
+ALTER TABLE transfer ADD UNIQUE KEY transfer_invoice_id (invoice_id);
+-- +migrate Down
+ALTER TABLE transfer DROP INDEX transfer_invoice_id;
@@ -1,13 +1,32 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"test/starkbank/config"
+	"test/starkbank/logging"
 	"test/starkbank/mocked/routes"
 )
 
+func main() {
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := config.Load(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
 
+	logs, err := logging.Setup(cfg.Log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer logs.Close()
 
-func main() {
-	e := routes.Api()
+	e := routes.Api(cfg)
 
-	e.Logger.Fatal(e.Start(":9090"))
+	e.Logger.Fatal(e.Start(":" + strconv.Itoa(cfg.Api.Port)))
 }
@@ -1,15 +1,20 @@
 package routes
 
 import (
+	"test/starkbank/config"
 	"test/starkbank/mocked/app"
 
 	"github.com/labstack/echo/v4"
 )
 
-func Api() *echo.Echo {
+func Api(cfg config.Config) *echo.Echo {
+	app.Configure(cfg)
+
 	e := echo.New()
 
 	e.POST("/invoice", app.CreateInvoice)
+	e.GET("/invoice/:id", app.ConsultInvoice)
+	e.POST("/transfer", app.MakeTransfer)
 
 	return e
 }
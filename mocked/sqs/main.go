@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"test/starkbank/config"
+	"test/starkbank/logging"
+	"time"
+)
+
+// main serves an in-memory, SQS-compatible API so the project can run
+// without AWS. Point AWS_ENDPOINT_URL at it and set any static keys.
+func main() {
+	flags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := config.Load(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	logs, err := logging.Setup(cfg.Log)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer logs.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	addr := ":" + strconv.Itoa(cfg.Sqs.Port)
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: newServer(cfg.AWS.Region),
+		// long polls end with the server instead of holding shutdown up
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Printf("SQS listening on %s, set AWS_ENDPOINT_URL=http://localhost%s\n", addr, addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("sqs server stopped", "err", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// contentType is the AWS JSON 1.0 protocol the SQS SDK speaks.
+const contentType = "application/x-amz-json-1.0"
+
+type (
+	// apiError is an SQS error as the SDK expects it: the code in __type and
+	// the legacy query code in the x-amzn-query-error header.
+	apiError struct {
+		status    int
+		code      string
+		queryCode string
+		message   string
+	}
+
+	attributeValue struct {
+		DataType    string
+		StringValue *string `json:",omitempty"`
+		BinaryValue []byte  `json:",omitempty"`
+	}
+
+	messageEntry struct {
+		Id                     string
+		MessageBody            string
+		DelaySeconds           int32
+		MessageAttributes      map[string]attributeValue
+		MessageDeduplicationId *string
+		MessageGroupId         *string
+	}
+
+	sendResult struct {
+		Id                     string `json:",omitempty"`
+		MessageId              string
+		MD5OfMessageBody       string
+		MD5OfMessageAttributes string `json:",omitempty"`
+	}
+
+	batchFailure struct {
+		Id          string
+		Code        string
+		Message     string
+		SenderFault bool
+	}
+
+	message struct {
+		MessageId              string
+		ReceiptHandle          string
+		Body                   string
+		MD5OfBody              string
+		Attributes             map[string]string         `json:",omitempty"`
+		MessageAttributes      map[string]attributeValue `json:",omitempty"`
+		MD5OfMessageAttributes string                    `json:",omitempty"`
+	}
+)
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func senderError(code string, queryCode string, format string, args ...any) *apiError {
+	return &apiError{status: http.StatusBadRequest, code: code, queryCode: queryCode, message: fmt.Sprintf(format, args...)}
+}
+
+func invalidParameter(format string, args ...any) *apiError {
+	return senderError("InvalidParameterValue", "InvalidParameterValue", format, args...)
+}
+
+func queueMissing(name string) *apiError {
+	return senderError("QueueDoesNotExist", "AWS.SimpleQueueService.NonExistentQueue", "the queue %s does not exist", name)
+}
+
+func fromWire(attributes map[string]attributeValue) map[string]types.MessageAttributeValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	values := make(map[string]types.MessageAttributeValue, len(attributes))
+	for name, value := range attributes {
+		values[name] = types.MessageAttributeValue{DataType: &value.DataType, StringValue: value.StringValue, BinaryValue: value.BinaryValue}
+	}
+
+	return values
+}
+
+func toWire(attributes map[string]types.MessageAttributeValue) map[string]attributeValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	values := make(map[string]attributeValue, len(attributes))
+	for name, value := range attributes {
+		wire := attributeValue{StringValue: value.StringValue, BinaryValue: value.BinaryValue}
+		if value.DataType != nil {
+			wire.DataType = *value.DataType
+		}
+		values[name] = wire
+	}
+
+	return values
+}
+
+func md5Hex(body string) string {
+	sum := md5.Sum([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// md5OfAttributes is the digest SQS returns for message attributes, which
+// the SDK checks every response against: for each attribute in name order,
+// the length-prefixed name and data type, a transport byte (1 for strings
+// and numbers, 2 for binary) and the length-prefixed value.
+func md5OfAttributes(attributes map[string]attributeValue) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := md5.New()
+	field := func(b []byte) {
+		binary.Write(hash, binary.BigEndian, uint32(len(b)))
+		hash.Write(b)
+	}
+	for _, name := range names {
+		value := attributes[name]
+		field([]byte(name))
+		field([]byte(value.DataType))
+		if value.BinaryValue != nil {
+			hash.Write([]byte{2})
+			field(value.BinaryValue)
+		} else {
+			hash.Write([]byte{1})
+			var s string
+			if value.StringValue != nil {
+				s = *value.StringValue
+			}
+			field([]byte(s))
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"test/starkbank/broker"
+	"test/starkbank/logging"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var logger = logging.For("sqs")
+
+const (
+	// accountId is the account every queue url and arn is under.
+	accountId   = "000000000000"
+	maxBodySize = 256 << 10
+	maxBatch    = 10
+)
+
+// queueName matches the names SQS accepts, .fifo included.
+var queueName = regexp.MustCompile(`^([A-Za-z0-9_-]{1,80}|[A-Za-z0-9_-]{1,75}\.fifo)$`)
+
+type (
+	// server answers the SQS JSON protocol on top of a broker.MemoryQueue,
+	// which keeps the FIFO ordering, deduplication window, visibility and
+	// redrive behaviour. Queue attributes it doesn't model are stored and
+	// echoed back.
+	server struct {
+		queues  *broker.MemoryQueue
+		region  string
+		actions map[string]func(c echo.Context) (any, error)
+
+		mu sync.Mutex
+		// attributes of every queue by name, as created or last set
+		attributes map[string]map[string]string
+	}
+
+	queueInput struct {
+		QueueUrl string
+	}
+
+	createQueueInput struct {
+		QueueName  string
+		Attributes map[string]string
+	}
+
+	sendMessageInput struct {
+		QueueUrl string
+		messageEntry
+	}
+
+	sendMessageBatchInput struct {
+		QueueUrl string
+		Entries  []messageEntry
+	}
+
+	receiveMessageInput struct {
+		QueueUrl                    string
+		MaxNumberOfMessages         *int32
+		WaitTimeSeconds             *int32
+		VisibilityTimeout           *int32
+		MessageAttributeNames       []string
+		MessageSystemAttributeNames []string
+		AttributeNames              []string
+	}
+
+	receiptInput struct {
+		Id            string
+		QueueUrl      string
+		ReceiptHandle string
+	}
+
+	deleteMessageBatchInput struct {
+		QueueUrl string
+		Entries  []receiptInput
+	}
+
+	changeVisibilityInput struct {
+		QueueUrl          string
+		ReceiptHandle     string
+		VisibilityTimeout int32
+	}
+
+	getAttributesInput struct {
+		QueueUrl       string
+		AttributeNames []string
+	}
+
+	setAttributesInput struct {
+		QueueUrl   string
+		Attributes map[string]string
+	}
+
+	batchOutput[T any] struct {
+		Successful []T
+		Failed     []batchFailure
+	}
+)
+
+func newServer(region string) *echo.Echo {
+	s := &server{
+		queues:     broker.NewMemoryQueue(),
+		region:     region,
+		attributes: map[string]map[string]string{},
+	}
+	s.actions = map[string]func(echo.Context) (any, error){
+		"CreateQueue":             action(s.createQueue),
+		"GetQueueUrl":             action(s.getQueueUrl),
+		"SendMessage":             action(s.sendMessage),
+		"SendMessageBatch":        action(s.sendMessageBatch),
+		"ReceiveMessage":          action(s.receiveMessage),
+		"DeleteMessage":           action(s.deleteMessage),
+		"DeleteMessageBatch":      action(s.deleteMessageBatch),
+		"PurgeQueue":              action(s.purgeQueue),
+		"ChangeMessageVisibility": action(s.changeMessageVisibility),
+		"GetQueueAttributes":      action(s.getQueueAttributes),
+		"SetQueueAttributes":      action(s.setQueueAttributes),
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.POST("/", s.dispatch)
+
+	return e
+}
+
+// action decodes the request body into the input of fn.
+func action[In any](fn func(c echo.Context, in In) (any, error)) func(echo.Context) (any, error) {
+	return func(c echo.Context) (any, error) {
+		var in In
+		if err := json.NewDecoder(c.Request().Body).Decode(&in); err != nil {
+			return nil, senderError("SerializationException", "SerializationException", "malformed request body: %s", err)
+		}
+
+		return fn(c, in)
+	}
+}
+
+// dispatch runs the action named in X-Amz-Target and writes its output, or
+// the error in the shape the SDK decodes.
+func (s *server) dispatch(c echo.Context) error {
+	name := strings.TrimPrefix(c.Request().Header.Get("X-Amz-Target"), "AmazonSQS.")
+
+	var out any
+	var err error
+	if run, ok := s.actions[name]; ok {
+		out, err = run(c)
+	} else {
+		err = senderError("UnsupportedOperation", "AWS.SimpleQueueService.UnsupportedOperation", "%q is not supported", name)
+	}
+
+	if err != nil {
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) {
+			apiErr = &apiError{status: http.StatusInternalServerError, code: "InternalError", queryCode: "InternalError", message: err.Error()}
+		}
+		fault := "Sender"
+		if apiErr.status >= 500 {
+			fault = "Receiver"
+		}
+		logger.Debug("request failed", "action", name, "code", apiErr.code, "err", apiErr.message)
+
+		c.Response().Header().Set("x-amzn-query-error", apiErr.queryCode+";"+fault)
+		return writeJson(c, apiErr.status, map[string]string{"__type": "com.amazonaws.sqs#" + apiErr.code, "message": apiErr.message})
+	}
+
+	logger.Debug("request", "action", name)
+	return writeJson(c, http.StatusOK, out)
+}
+
+func writeJson(c echo.Context, status int, out any) error {
+	body, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(status, contentType, body)
+}
+
+func (s *server) createQueue(c echo.Context, in createQueueInput) (any, error) {
+	if !queueName.MatchString(in.QueueName) {
+		return nil, invalidParameter("queue name %q must be up to 80 letters, digits, - or _", in.QueueName)
+	}
+	fifo := strings.HasSuffix(in.QueueName, ".fifo")
+	if (in.Attributes["FifoQueue"] == "true") != fifo {
+		return nil, invalidParameter("FifoQueue must be true for, and only for, names ending in .fifo")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.attributes[in.QueueName]; ok {
+		for key, value := range in.Attributes {
+			if existing[key] != value {
+				return nil, senderError("QueueNameExists", "QueueAlreadyExists", "queue %s already exists with a different %s", in.QueueName, key)
+			}
+		}
+		return map[string]string{"QueueUrl": s.url(c, in.QueueName)}, nil
+	}
+
+	attributes := map[string]string{
+		"VisibilityTimeout":             "30",
+		"ReceiveMessageWaitTimeSeconds": "0",
+		"DelaySeconds":                  "0",
+		"MaximumMessageSize":            strconv.Itoa(maxBodySize),
+		"MessageRetentionPeriod":        "345600",
+		"CreatedTimestamp":              strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	if fifo {
+		attributes["FifoQueue"] = "true"
+		attributes["ContentBasedDeduplication"] = "false"
+	}
+	maps.Copy(attributes, in.Attributes)
+
+	url, err := s.queues.CreateSqsQueue(c.Request().Context(), in.QueueName, fifo)
+	if err != nil {
+		return nil, err
+	}
+	if policy, ok := attributes["RedrivePolicy"]; ok {
+		if err := s.setRedrive(c.Request().Context(), url, policy); err != nil {
+			return nil, err
+		}
+	}
+	s.attributes[in.QueueName] = attributes
+
+	return map[string]string{"QueueUrl": s.url(c, in.QueueName)}, nil
+}
+
+func (s *server) getQueueUrl(c echo.Context, in createQueueInput) (any, error) {
+	if _, err := s.queues.GetQueue(c.Request().Context(), in.QueueName); err != nil {
+		return nil, fromQueue(err, in.QueueName)
+	}
+
+	return map[string]string{"QueueUrl": s.url(c, in.QueueName)}, nil
+}
+
+func (s *server) sendMessage(c echo.Context, in sendMessageInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.send(c.Request().Context(), name, url, in.messageEntry)
+}
+
+func (s *server) sendMessageBatch(c echo.Context, in sendMessageBatchInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(in.Entries))
+	for i, entry := range in.Entries {
+		ids[i] = entry.Id
+	}
+	if err := checkBatch(ids); err != nil {
+		return nil, err
+	}
+
+	out := batchOutput[sendResult]{Successful: []sendResult{}, Failed: []batchFailure{}}
+	for _, entry := range in.Entries {
+		result, err := s.send(c.Request().Context(), name, url, entry)
+		if err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				return nil, err
+			}
+			out.Failed = append(out.Failed, batchFailure{Id: entry.Id, Code: apiErr.code, Message: apiErr.message, SenderFault: apiErr.status < 500})
+			continue
+		}
+		result.Id = entry.Id
+		out.Successful = append(out.Successful, result)
+	}
+
+	return out, nil
+}
+
+func (s *server) send(ctx context.Context, name string, url string, entry messageEntry) (sendResult, error) {
+	if entry.MessageBody == "" {
+		return sendResult{}, senderError("MissingParameter", "MissingParameter", "MessageBody is required")
+	}
+	if len(entry.MessageBody) > maxBodySize {
+		return sendResult{}, invalidParameter("MessageBody can't be longer than %d bytes", maxBodySize)
+	}
+	if entry.DelaySeconds != 0 {
+		return sendResult{}, invalidParameter("DelaySeconds is not supported")
+	}
+	if strings.HasSuffix(name, ".fifo") && entry.MessageDeduplicationId == nil && s.attribute(name, "ContentBasedDeduplication") != "true" {
+		return sendResult{}, invalidParameter("the queue should either have ContentBasedDeduplication enabled or MessageDeduplicationId provided explicitly")
+	}
+
+	id, err := s.queues.Send(ctx, url, []byte(entry.MessageBody), fromWire(entry.MessageAttributes), entry.MessageGroupId, entry.MessageDeduplicationId)
+	if err != nil {
+		return sendResult{}, fromQueue(err, name)
+	}
+
+	return sendResult{
+		MessageId:              id,
+		MD5OfMessageBody:       md5Hex(entry.MessageBody),
+		MD5OfMessageAttributes: md5OfAttributes(entry.MessageAttributes),
+	}, nil
+}
+
+func (s *server) receiveMessage(c echo.Context, in receiveMessageInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	max := 1
+	if in.MaxNumberOfMessages != nil {
+		max = int(*in.MaxNumberOfMessages)
+	}
+	if max < 1 || max > maxBatch {
+		return nil, invalidParameter("MaxNumberOfMessages must be between 1 and %d", maxBatch)
+	}
+	wait, _ := strconv.Atoi(s.attribute(name, "ReceiveMessageWaitTimeSeconds"))
+	if in.WaitTimeSeconds != nil {
+		wait = int(*in.WaitTimeSeconds)
+	}
+	if wait < 0 || wait > 20 {
+		return nil, invalidParameter("WaitTimeSeconds must be between 0 and 20")
+	}
+	visibility, _ := strconv.Atoi(s.attribute(name, "VisibilityTimeout"))
+	if in.VisibilityTimeout != nil {
+		visibility = int(*in.VisibilityTimeout)
+	}
+
+	received, err := s.queues.Receive(c.Request().Context(), url, max, time.Duration(wait)*time.Second, time.Duration(visibility)*time.Second)
+	if err != nil {
+		return nil, fromQueue(err, name)
+	}
+
+	system := append(in.MessageSystemAttributeNames, in.AttributeNames...)
+	messages := make([]message, 0, len(received))
+	for _, msg := range received {
+		out := message{
+			MessageId:     *msg.MessageId,
+			ReceiptHandle: *msg.ReceiptHandle,
+			Body:          *msg.Body,
+			MD5OfBody:     md5Hex(*msg.Body),
+			Attributes:    map[string]string{},
+		}
+		for key, value := range msg.Attributes {
+			if selected(key, system) {
+				out.Attributes[key] = value
+			}
+		}
+		for key, value := range toWire(msg.MessageAttributes) {
+			if !selected(key, in.MessageAttributeNames) {
+				continue
+			}
+			if out.MessageAttributes == nil {
+				out.MessageAttributes = map[string]attributeValue{}
+			}
+			out.MessageAttributes[key] = value
+		}
+		out.MD5OfMessageAttributes = md5OfAttributes(out.MessageAttributes)
+		messages = append(messages, out)
+	}
+
+	return map[string][]message{"Messages": messages}, nil
+}
+
+func (s *server) deleteMessage(c echo.Context, in receiptInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, s.delete(c.Request().Context(), name, url, in.ReceiptHandle)
+}
+
+func (s *server) deleteMessageBatch(c echo.Context, in deleteMessageBatchInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(in.Entries))
+	for i, entry := range in.Entries {
+		ids[i] = entry.Id
+	}
+	if err := checkBatch(ids); err != nil {
+		return nil, err
+	}
+
+	out := batchOutput[map[string]string]{Successful: []map[string]string{}, Failed: []batchFailure{}}
+	for _, entry := range in.Entries {
+		err := s.delete(c.Request().Context(), name, url, entry.ReceiptHandle)
+		if err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				return nil, err
+			}
+			out.Failed = append(out.Failed, batchFailure{Id: entry.Id, Code: apiErr.code, Message: apiErr.message, SenderFault: apiErr.status < 500})
+			continue
+		}
+		out.Successful = append(out.Successful, map[string]string{"Id": entry.Id})
+	}
+
+	return out, nil
+}
+
+func (s *server) delete(ctx context.Context, name string, url string, handle string) error {
+	err := s.queues.DeleteMessage(ctx, url, handle)
+	if errors.Is(err, broker.ErrValidation) {
+		return senderError("ReceiptHandleIsInvalid", "ReceiptHandleIsInvalid", "receipt handle %q is not valid", handle)
+	}
+
+	return fromQueue(err, name)
+}
+
+func (s *server) purgeQueue(c echo.Context, in queueInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, fromQueue(s.queues.PurgeQueue(c.Request().Context(), url), name)
+}
+
+func (s *server) changeMessageVisibility(c echo.Context, in changeVisibilityInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	if in.VisibilityTimeout < 0 || in.VisibilityTimeout > 43200 {
+		return nil, invalidParameter("VisibilityTimeout must be between 0 and 43200")
+	}
+
+	err = s.queues.ChangeMessageVisibility(c.Request().Context(), url, in.ReceiptHandle, time.Duration(in.VisibilityTimeout)*time.Second)
+	if errors.Is(err, broker.ErrValidation) {
+		return nil, senderError("MessageNotInflight", "AWS.SimpleQueueService.MessageNotInflight", "%s", err)
+	}
+
+	return struct{}{}, fromQueue(err, name)
+}
+
+func (s *server) getQueueAttributes(c echo.Context, in getAttributesInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.queues.Stats(c.Request().Context(), url)
+	if err != nil {
+		return nil, fromQueue(err, name)
+	}
+
+	s.mu.Lock()
+	all := maps.Clone(s.attributes[name])
+	s.mu.Unlock()
+	all["QueueArn"] = s.arn(name)
+	all["ApproximateNumberOfMessages"] = strconv.Itoa(stats.Visible)
+	all["ApproximateNumberOfMessagesNotVisible"] = strconv.Itoa(stats.InFlight)
+	all["ApproximateNumberOfMessagesDelayed"] = "0"
+
+	attributes := map[string]string{}
+	for key, value := range all {
+		if selected(key, in.AttributeNames) {
+			attributes[key] = value
+		}
+	}
+
+	return map[string]map[string]string{"Attributes": attributes}, nil
+}
+
+func (s *server) setQueueAttributes(c echo.Context, in setAttributesInput) (any, error) {
+	name, url, err := s.lookup(c.Request().Context(), in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	if policy, ok := in.Attributes["RedrivePolicy"]; ok {
+		if err := s.setRedrive(c.Request().Context(), url, policy); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maps.Copy(s.attributes[name], in.Attributes)
+
+	return struct{}{}, nil
+}
+
+// setRedrive applies a RedrivePolicy attribute to the memory queue, its
+// target is looked up by the queue name at the end of the arn.
+func (s *server) setRedrive(ctx context.Context, url string, policy string) error {
+	var redrive struct {
+		DeadLetterTargetArn string      `json:"deadLetterTargetArn"`
+		MaxReceiveCount     json.Number `json:"maxReceiveCount"`
+	}
+	if err := json.Unmarshal([]byte(policy), &redrive); err != nil {
+		return invalidParameter("RedrivePolicy is not valid JSON: %s", err)
+	}
+	maxReceiveCount, err := strconv.Atoi(redrive.MaxReceiveCount.String())
+	if err != nil {
+		return invalidParameter("RedrivePolicy maxReceiveCount %q is not a number", redrive.MaxReceiveCount)
+	}
+
+	dlqName := redrive.DeadLetterTargetArn[strings.LastIndex(redrive.DeadLetterTargetArn, ":")+1:]
+	dlqUrl, err := s.queues.GetQueue(ctx, dlqName)
+	if err != nil {
+		return senderError("InvalidParameterValue", "InvalidParameterValue", "dead-letter target %s does not exist", redrive.DeadLetterTargetArn)
+	}
+
+	return fromQueue(s.queues.AttachDeadLetterQueue(ctx, url, dlqUrl, maxReceiveCount), dlqName)
+}
+
+// lookup resolves the queue url of a request to the queue name and the url
+// of the memory queue behind it.
+func (s *server) lookup(ctx context.Context, queueUrl string) (string, string, error) {
+	if queueUrl == "" {
+		return "", "", senderError("MissingParameter", "MissingParameter", "QueueUrl is required")
+	}
+
+	name := path.Base(queueUrl)
+	url, err := s.queues.GetQueue(ctx, name)
+	if err != nil {
+		return "", "", fromQueue(err, name)
+	}
+
+	return name, url, nil
+}
+
+func (s *server) attribute(name string, key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.attributes[name][key]
+}
+
+func (s *server) url(c echo.Context, name string) string {
+	return c.Scheme() + "://" + c.Request().Host + "/" + accountId + "/" + name
+}
+
+func (s *server) arn(name string) string {
+	return "arn:aws:sqs:" + s.region + ":" + accountId + ":" + name
+}
+
+// fromQueue turns a memory queue error into the SQS error for it.
+func fromQueue(err error, name string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, broker.ErrNotFound):
+		return queueMissing(name)
+	case errors.Is(err, broker.ErrValidation):
+		return invalidParameter("%s", err)
+	}
+
+	return err
+}
+
+func checkBatch(ids []string) error {
+	if len(ids) == 0 {
+		return senderError("EmptyBatchRequest", "AWS.SimpleQueueService.EmptyBatchRequest", "the batch request doesn't contain any entries")
+	}
+	if len(ids) > maxBatch {
+		return senderError("TooManyEntriesInBatchRequest", "AWS.SimpleQueueService.TooManyEntriesInBatchRequest", "at most %d entries are allowed in a batch", maxBatch)
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			return senderError("BatchEntryIdsNotDistinct", "AWS.SimpleQueueService.BatchEntryIdsNotDistinct", "id %s is repeated", id)
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
+
+// selected reports whether name is asked for by names, which may hold All,
+// .* or prefixes ending in .*.
+func selected(name string, names []string) bool {
+	for _, n := range names {
+		if n == "All" || n == ".*" || n == name {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(n, ".*"); ok && strings.HasPrefix(name, prefix+".") {
+			return true
+		}
+	}
+
+	return false
+}
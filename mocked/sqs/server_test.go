@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+)
+
+// newClient starts a server and returns the SDK client pointed at it, the
+// way the project uses it, with a FIFO queue created with attributes.
+func newClient(t *testing.T, attributes map[string]string) (*sqs.Client, string) {
+	t.Helper()
+
+	srv := httptest.NewServer(newServer("us-east-1"))
+	t.Cleanup(srv.Close)
+
+	client := sqs.New(sqs.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+	})
+
+	created, err := client.CreateQueue(context.Background(), &sqs.CreateQueueInput{
+		QueueName:  aws.String("invoices.fifo"),
+		Attributes: map[string]string{"FifoQueue": "true", "VisibilityTimeout": "60"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attributes) > 0 {
+		if _, err := client.SetQueueAttributes(context.Background(), &sqs.SetQueueAttributesInput{QueueUrl: created.QueueUrl, Attributes: attributes}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return client, *created.QueueUrl
+}
+
+func send(t *testing.T, client *sqs.Client, url string, body string, group string, dedupId string) string {
+	t.Helper()
+
+	input := &sqs.SendMessageInput{QueueUrl: &url, MessageBody: &body, MessageGroupId: &group}
+	if dedupId != "" {
+		input.MessageDeduplicationId = &dedupId
+	}
+	out, err := client.SendMessage(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return *out.MessageId
+}
+
+func receive(t *testing.T, client *sqs.Client, url string, max int32) []types.Message {
+	t.Helper()
+
+	out, err := client.ReceiveMessage(context.Background(), &sqs.ReceiveMessageInput{QueueUrl: &url, MaxNumberOfMessages: max})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Messages
+}
+
+func bodies(messages []types.Message) []string {
+	out := make([]string, len(messages))
+	for i, m := range messages {
+		out[i] = *m.Body
+	}
+	return out
+}
+
+func TestFifoOrder(t *testing.T) {
+	client, url := newClient(t, nil)
+	for _, body := range []string{"a1", "a2", "a3"} {
+		send(t, client, url, body, "a", body)
+	}
+	send(t, client, url, "b1", "b", "b1")
+
+	var got []string
+	for range 4 {
+		messages := receive(t, client, url, 1)
+		if len(messages) != 1 {
+			t.Fatalf("received %d messages after %v, want 1", len(messages), got)
+		}
+		got = append(got, *messages[0].Body)
+
+		if _, err := client.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{QueueUrl: &url, ReceiptHandle: messages[0].ReceiptHandle}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var fromA []string
+	for _, body := range got {
+		if body[0] == 'a' {
+			fromA = append(fromA, body)
+		}
+	}
+	if len(fromA) != 3 || fromA[0] != "a1" || fromA[1] != "a2" || fromA[2] != "a3" {
+		t.Fatalf("group a received as %v, want a1 a2 a3", fromA)
+	}
+	if rest := receive(t, client, url, 10); len(rest) != 0 {
+		t.Fatalf("received %v from an empty queue", bodies(rest))
+	}
+}
+
+func TestFifoGroupLockedWhileInFlight(t *testing.T) {
+	client, url := newClient(t, nil)
+	send(t, client, url, "a1", "a", "a1")
+	send(t, client, url, "a2", "a", "a2")
+	send(t, client, url, "b1", "b", "b1")
+
+	first := receive(t, client, url, 1)
+	if got := bodies(first); len(got) != 1 || got[0] != "a1" {
+		t.Fatalf("first receive = %v, want [a1]", got)
+	}
+
+	// a2 waits for a1 to be deleted, b is not held up by a
+	if got := bodies(receive(t, client, url, 10)); len(got) != 1 || got[0] != "b1" {
+		t.Fatalf("receive with a1 in flight = %v, want [b1]", got)
+	}
+
+	if _, err := client.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{QueueUrl: &url, ReceiptHandle: first[0].ReceiptHandle}); err != nil {
+		t.Fatal(err)
+	}
+	if got := bodies(receive(t, client, url, 10)); len(got) != 1 || got[0] != "a2" {
+		t.Fatalf("receive after deleting a1 = %v, want [a2]", got)
+	}
+}
+
+func TestDeduplication(t *testing.T) {
+	type message struct {
+		body    string
+		dedupId string
+	}
+
+	tests := []struct {
+		name         string
+		contentDedup bool
+		messages     []message
+		wantBodies   int
+		wantErr      string
+	}{
+		{
+			name:       "same deduplication id is sent once",
+			messages:   []message{{"one", "dup-1"}, {"two", "dup-1"}},
+			wantBodies: 1,
+		},
+		{
+			name:       "different deduplication ids are both sent",
+			messages:   []message{{"one", "dup-1"}, {"one", "dup-2"}},
+			wantBodies: 2,
+		},
+		{
+			name:         "content based deduplication drops the same body",
+			contentDedup: true,
+			messages:     []message{{"same", ""}, {"same", ""}, {"other", ""}},
+			wantBodies:   2,
+		},
+		{
+			name:         "explicit id wins over the content",
+			contentDedup: true,
+			messages:     []message{{"same", "dup-1"}, {"same", "dup-2"}},
+			wantBodies:   2,
+		},
+		{
+			name:     "no id without content based deduplication",
+			messages: []message{{"one", ""}},
+			wantErr:  "InvalidParameterValue",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attributes map[string]string
+			if tt.contentDedup {
+				attributes = map[string]string{"ContentBasedDeduplication": "true"}
+			}
+			client, url := newClient(t, attributes)
+
+			ids := map[string]string{}
+			for i, m := range tt.messages {
+				input := &sqs.SendMessageInput{QueueUrl: &url, MessageBody: aws.String(m.body), MessageGroupId: aws.String("g")}
+				if m.dedupId != "" {
+					input.MessageDeduplicationId = aws.String(m.dedupId)
+				}
+				out, err := client.SendMessage(context.Background(), input)
+				if tt.wantErr != "" {
+					var apiErr smithy.APIError
+					if !errors.As(err, &apiErr) || apiErr.ErrorCode() != tt.wantErr {
+						t.Fatalf("SendMessage() = %v, want %s", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// a deduplicated send still succeeds and gets the id of the
+				// message it repeats
+				key := m.dedupId
+				if key == "" {
+					key = m.body
+				}
+				if previous, ok := ids[key]; ok && previous != *out.MessageId {
+					t.Fatalf("message %d got id %s, want the id %s of the message it repeats", i, *out.MessageId, previous)
+				}
+				ids[key] = *out.MessageId
+			}
+
+			got := 0
+			for {
+				messages := receive(t, client, url, 10)
+				if len(messages) == 0 {
+					break
+				}
+				for _, m := range messages {
+					got++
+					client.DeleteMessage(context.Background(), &sqs.DeleteMessageInput{QueueUrl: &url, ReceiptHandle: m.ReceiptHandle})
+				}
+			}
+			if got != tt.wantBodies {
+				t.Fatalf("received %d messages, want %d", got, tt.wantBodies)
+			}
+		})
+	}
+}
+
+func TestDeduplicationAcrossBatch(t *testing.T) {
+	client, url := newClient(t, nil)
+
+	entry := func(id string, dedupId string) types.SendMessageBatchRequestEntry {
+		return types.SendMessageBatchRequestEntry{
+			Id:                     aws.String(id),
+			MessageBody:            aws.String("body-" + id),
+			MessageGroupId:         aws.String("g"),
+			MessageDeduplicationId: aws.String(dedupId),
+		}
+	}
+	out, err := client.SendMessageBatch(context.Background(), &sqs.SendMessageBatchInput{
+		QueueUrl: &url,
+		Entries:  []types.SendMessageBatchRequestEntry{entry("1", "dup-1"), entry("2", "dup-2"), entry("3", "dup-1")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Successful) != 3 || len(out.Failed) != 0 {
+		t.Fatalf("SendMessageBatch() = %d successful, %d failed, want 3 and 0", len(out.Successful), len(out.Failed))
+	}
+
+	// one receive takes several messages of a group, in order
+	got := bodies(receive(t, client, url, 10))
+	if len(got) != 2 || got[0] != "body-1" || got[1] != "body-2" {
+		t.Fatalf("received %v, want [body-1 body-2]", got)
+	}
+}
@@ -0,0 +1,328 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+type (
+	// Money is an amount in integer cents, so sums and differences are
+	// exact. Anything that can produce fractions of a cent takes a Rounding.
+	Money int64
+
+	// Mode is how amounts are written in JSON and form values.
+	Mode int32
+
+	// Rounding decides what happens to fractions of a cent.
+	Rounding int
+)
+
+const (
+	// Decimal writes amounts in reais with two decimals, 4000.10.
+	Decimal Mode = iota
+	// Cents writes amounts as integer cents, 400010.
+	Cents
+)
+
+const (
+	// HalfUp rounds halves away from zero, 0.125 is 0.13.
+	HalfUp Rounding = iota
+	// HalfEven rounds halves to the even cent, 0.125 is 0.12.
+	HalfEven
+	// Down drops fractions of a cent, rounding towards zero.
+	Down
+	// Up rounds any fraction of a cent away from zero.
+	Up
+)
+
+var (
+	ErrSyntax    = errors.New("amount is not a number")
+	ErrPrecision = errors.New("amount has fractions of a cent")
+	ErrRange     = errors.New("amount is out of range")
+)
+
+// mode is the JSON mode, set once at startup by SetMode. Decimal is the
+// default because it is what the API and the queued messages always used.
+var mode atomic.Int32
+
+// SetMode sets how every Money is written to and read from JSON. Both
+// sides of the API and the queue must agree, an amount of 3570 is 3570.00
+// in Decimal mode and 35.70 in Cents mode.
+func SetMode(m Mode) {
+	mode.Store(int32(m))
+}
+
+func CurrentMode() Mode {
+	return Mode(mode.Load())
+}
+
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "decimal":
+		return Decimal, nil
+	case "cents":
+		return Cents, nil
+	default:
+		return Decimal, fmt.Errorf("unknown money mode %q, use decimal or cents", s)
+	}
+}
+
+func (m Mode) String() string {
+	if m == Cents {
+		return "cents"
+	}
+	return "decimal"
+}
+
+func FromCents(cents int64) Money {
+	return Money(cents)
+}
+
+// FromFloat converts reais to Money, rounding fractions of a cent with r.
+// The float is read as its shortest decimal form, so 4000.10 is 400010
+// cents and not the 400009.99... of 4000.10*100. Values past the range of
+// Money saturate, NaN is zero.
+func FromFloat(reais float64, r Rounding) Money {
+	switch {
+	case math.IsNaN(reais):
+		return 0
+	case reais >= math.MaxInt64/100:
+		return math.MaxInt64
+	case reais <= math.MinInt64/100:
+		return math.MinInt64
+	}
+
+	m, _ := parse(strconv.FormatFloat(reais, 'f', -1, 64), r, false)
+	return m
+}
+
+// Parse reads an amount in reais such as "4000.10", "-3.4" or "12". More
+// than two decimals are refused rather than rounded.
+func Parse(s string) (Money, error) {
+	return parse(s, HalfUp, true)
+}
+
+// ParseCents reads an amount in integer cents such as "400010".
+func ParseCents(s string) (Money, error) {
+	cents, err := strconv.ParseInt(s, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("%w: %s", ErrRange, s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a whole number of cents", ErrSyntax, s)
+	}
+
+	return Money(cents), nil
+}
+
+// parse reads a plain decimal number of reais. Digits past the cents are
+// rounded with r, or refused when strict is set.
+func parse(s string, r Rounding, strict bool) (Money, error) {
+	digits := s
+	negative := strings.HasPrefix(digits, "-")
+	if negative || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" || !onlyDigits(whole) || !onlyDigits(fraction) {
+		return 0, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+
+	cents := new(big.Int)
+	cents.SetString("0"+whole+(fraction + "00")[:2], 10)
+	rest := ""
+	if len(fraction) > 2 {
+		rest = strings.TrimRight(fraction[2:], "0")
+	}
+	if rest != "" {
+		if strict {
+			return 0, fmt.Errorf("%w: %s", ErrPrecision, s)
+		}
+		// rest is the fraction of a cent, compared with a half as
+		// "5" padded to the same length
+		half := strings.Compare(rest, "5"+strings.Repeat("0", len(rest)-1))
+		if away(r, half, cents.Bit(0) == 1) {
+			cents.Add(cents, big.NewInt(1))
+		}
+	}
+	if negative {
+		cents.Neg(cents)
+	}
+
+	if !cents.IsInt64() {
+		return 0, fmt.Errorf("%w: %s", ErrRange, s)
+	}
+	return Money(cents.Int64()), nil
+}
+
+func onlyDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// away reports whether a dropped fraction of a cent, compared with a half
+// by half (-1, 0 or 1), rounds the cents away from zero. odd is whether
+// the cents kept are odd, which only HalfEven looks at.
+func away(r Rounding, half int, odd bool) bool {
+	switch r {
+	case Down:
+		return false
+	case Up:
+		return true
+	case HalfEven:
+		return half > 0 || half == 0 && odd
+	default:
+		return half >= 0
+	}
+}
+
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// Float is m in reais, for display and metrics only.
+func (m Money) Float() float64 {
+	return float64(m) / 100
+}
+
+// String is m in reais with two decimals, "4000.10".
+func (m Money) String() string {
+	cents := int64(m)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+	}
+	abs := new(big.Int).Abs(big.NewInt(cents))
+	whole, fraction := new(big.Int).QuoRem(abs, big.NewInt(100), new(big.Int))
+
+	return fmt.Sprintf("%s%s.%02d", sign, whole, fraction.Int64())
+}
+
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+// Percent is rate percent of m, such as a fine of 2% over the amount.
+// Rates are taken to a millionth of a percent.
+func (m Money) Percent(rate float64, r Rounding) Money {
+	return m.scale(rate, 1, r)
+}
+
+// Interest is simple interest on m at rate percent a month for days, with
+// 30 day months: 1% a month over 15 days is 0.5% of m.
+func (m Money) Interest(rate float64, days int, r Rounding) Money {
+	return m.scale(rate*float64(days), 30, r)
+}
+
+// scale is m * rate% / divisor rounded with r. The product is computed in
+// integers so halves are exact halves and r alone decides them.
+func (m Money) scale(rate float64, divisor int64, r Rounding) Money {
+	const precision = 1_000_000
+
+	num := big.NewInt(int64(m))
+	num.Mul(num, big.NewInt(int64(math.Round(rate*precision))))
+	den := big.NewInt(100 * precision * divisor)
+
+	cents, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		half := new(big.Int).Abs(rem)
+		half.Lsh(half, 1)
+		if away(r, half.Cmp(den), cents.Bit(0) == 1) {
+			cents.Add(cents, big.NewInt(int64(num.Sign())))
+		}
+	}
+
+	if !cents.IsInt64() {
+		if cents.Sign() < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return Money(cents.Int64())
+}
+
+// MarshalJSON writes m as a number in the current Mode.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if CurrentMode() == Cents {
+		return strconv.AppendInt(nil, int64(m), 10), nil
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads a number, or a quoted number, in the current Mode.
+// Decimal amounts with fractions of a cent are refused, and so are
+// fractions in Cents mode. null leaves m as it is.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	return m.UnmarshalText([]byte(s))
+}
+
+// UnmarshalText reads form and query values in the current Mode.
+func (m *Money) UnmarshalText(text []byte) error {
+	var parsed Money
+	var err error
+	if CurrentMode() == Cents {
+		parsed, err = ParseCents(string(text))
+	} else {
+		parsed, err = Parse(string(text))
+	}
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Value stores m as integer cents in a BIGINT column.
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+// Scan reads a BIGINT column of cents.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*m = Money(v)
+		return nil
+	case []byte:
+		parsed, err := ParseCents(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseCents(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case float64:
+		return fmt.Errorf("money column holds %v, it must be a BIGINT of cents: run the migrations", v)
+	default:
+		return fmt.Errorf("can't scan %T into money", src)
+	}
+}
@@ -0,0 +1,197 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error
+	}{
+		{in: "4000.10", want: 400010},
+		{in: "4000.1", want: 400010},
+		{in: "12", want: 1200},
+		{in: ".5", want: 50},
+		{in: "3.", want: 300},
+		{in: "-3.4", want: -340},
+		{in: "+0.01", want: 1},
+		{in: "1.2300", want: 123},
+		{in: "1.234", wantErr: ErrPrecision},
+		{in: "", wantErr: ErrSyntax},
+		{in: ".", wantErr: ErrSyntax},
+		{in: "1,50", wantErr: ErrSyntax},
+		{in: "1e3", wantErr: ErrSyntax},
+		{in: " 1", wantErr: ErrSyntax},
+		{in: "92233720368547758.08", wantErr: ErrRange},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if !errors.Is(err, tt.wantErr) || err == nil && got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v, want %d, %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCents(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr error
+	}{
+		{in: "400010", want: 400010},
+		{in: "-35", want: -35},
+		{in: "40.5", wantErr: ErrSyntax},
+		{in: "", wantErr: ErrSyntax},
+		{in: "9223372036854775808", wantErr: ErrRange},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCents(tt.in)
+		if !errors.Is(err, tt.wantErr) || err == nil && got != tt.want {
+			t.Errorf("ParseCents(%q) = %d, %v, want %d, %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	tests := []struct {
+		in   float64
+		r    Rounding
+		want Money
+	}{
+		{in: 4000.10, r: HalfUp, want: 400010},
+		{in: 0.125, r: HalfUp, want: 13},
+		{in: -0.125, r: HalfUp, want: -13},
+		{in: 0.125, r: HalfEven, want: 12},
+		{in: 0.135, r: HalfEven, want: 14},
+		{in: 0.1251, r: HalfEven, want: 13},
+		{in: 0.129, r: Down, want: 12},
+		{in: -0.129, r: Down, want: -12},
+		{in: 0.121, r: Up, want: 13},
+		{in: -0.121, r: Up, want: -13},
+		{in: 0.12, r: Up, want: 12},
+		{in: math.NaN(), r: HalfUp, want: 0},
+		{in: math.Inf(1), r: HalfUp, want: math.MaxInt64},
+		{in: math.Inf(-1), r: HalfUp, want: math.MinInt64},
+	}
+
+	for _, tt := range tests {
+		if got := FromFloat(tt.in, tt.r); got != tt.want {
+			t.Errorf("FromFloat(%v, %d) = %d, want %d", tt.in, tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestPercentAndInterest(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Money
+		want Money
+	}{
+		{name: "2% fine", got: Money(400010).Percent(2, HalfUp), want: 8000},
+		{name: "half cent up", got: Money(125).Percent(10, HalfUp), want: 13},
+		{name: "half cent even", got: Money(125).Percent(10, HalfEven), want: 12},
+		{name: "half cent down", got: Money(125).Percent(10, Down), want: 12},
+		{name: "any fraction up", got: Money(121).Percent(10, Up), want: 13},
+		{name: "negative half up", got: Money(-125).Percent(10, HalfUp), want: -13},
+		{name: "1% a month over 15 days", got: Money(10000).Interest(1, 15, HalfUp), want: 50},
+		{name: "interest rounded down", got: Money(10001).Interest(1, 15, Down), want: 50},
+		{name: "interest rounded up", got: Money(10001).Interest(1, 15, Up), want: 51},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{in: 400010, want: "4000.10"},
+		{in: 5, want: "0.05"},
+		{in: 0, want: "0.00"},
+		{in: -340, want: "-3.40"},
+		{in: math.MinInt64, want: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	t.Cleanup(func() { SetMode(Decimal) })
+
+	tests := []struct {
+		name     string
+		mode     Mode
+		in       Money
+		json     string
+		input    string
+		want     Money
+		wantErr  error
+		previous Money
+	}{
+		{name: "decimal", mode: Decimal, in: 400010, json: "4000.10", input: "4000.10", want: 400010},
+		{name: "decimal quoted", mode: Decimal, in: 5, json: "0.05", input: `"0.05"`, want: 5},
+		{name: "decimal fraction of a cent", mode: Decimal, input: "1.234", wantErr: ErrPrecision},
+		{name: "cents", mode: Cents, in: 400010, json: "400010", input: "400010", want: 400010},
+		{name: "cents quoted", mode: Cents, in: -35, json: "-35", input: `"-35"`, want: -35},
+		{name: "cents with a fraction", mode: Cents, input: "40.5", wantErr: ErrSyntax},
+		{name: "null keeps the value", mode: Cents, input: "null", previous: 700, want: 700},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetMode(tt.mode)
+
+			if tt.json != "" {
+				data, err := json.Marshal(tt.in)
+				if err != nil || string(data) != tt.json {
+					t.Fatalf("Marshal(%d) = %s, %v, want %s", tt.in, data, err, tt.json)
+				}
+			}
+
+			got := tt.previous
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if !errors.Is(err, tt.wantErr) || err == nil && got != tt.want {
+				t.Fatalf("Unmarshal(%s) = %d, %v, want %d, %v", tt.input, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src     any
+		want    Money
+		wantErr bool
+	}{
+		{src: int64(400010), want: 400010},
+		{src: []byte("400010"), want: 400010},
+		{src: "-35", want: -35},
+		{src: "40.5", wantErr: true},
+		{src: 4000.10, wantErr: true},
+		{src: nil, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		var got Money
+		err := got.Scan(tt.src)
+		if (err != nil) != tt.wantErr || err == nil && got != tt.want {
+			t.Errorf("Scan(%#v) = %d, %v, want %d", tt.src, got, err, tt.want)
+		}
+	}
+}
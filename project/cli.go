@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"test/starkbank/project/queue"
+)
+
+// Exit codes of the project binary.
+const (
+	exitOk = 0
+	// exitFailure is a command that ran and failed.
+	exitFailure = 1
+	// exitUsage is an unknown command, a bad flag or a bad argument.
+	exitUsage = 2
+	// exitConfig is a configuration that doesn't validate or load.
+	exitConfig = 3
+	// exitUnavailable is a queue that can't be reached, throttles us past
+	// every retry or rejects the credentials.
+	exitUnavailable = 4
+)
+
+// errConfig marks the configuration errors found once a command runs, such
+// as AWS credentials that don't resolve.
+var errConfig = errors.New("invalid configuration")
+
+type (
+	// command is one entry of the CLI. Commands with subcommands only pick
+	// the subcommand, they have no run of their own.
+	command struct {
+		name     string
+		synopsis string
+		summary  string
+		run      func(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error
+		sub      []command
+	}
+
+	// usageError is a command line the command can't make sense of.
+	usageError struct {
+		err error
+		// printed is set when the flag package already reported err
+		printed bool
+	}
+)
+
+func (u usageError) Error() string {
+	return u.err.Error()
+}
+
+func (u usageError) Unwrap() error {
+	return u.err
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{err: fmt.Errorf(format, args...)}
+}
+
+var commands = []command{
+	{name: "run", synopsis: "run", summary: "queue invoices on SCHEDULE and consume them until the schedule is over", run: runCmd},
+	{name: "produce", synopsis: "produce [-n count | -file path] [-group id]", summary: "queue generated invoices, or the invoices in a file", run: produceCmd},
+	{name: "consume", synopsis: "consume [-for duration]", summary: "handle queued messages until stopped", run: consumeCmd},
+	{name: "queue", synopsis: "queue <purge|stats|peek>", summary: "inspect or empty the queue", sub: []command{
+		{name: "purge", synopsis: "queue purge -yes [-dlq]", summary: "delete every message in the queue", run: purgeCmd},
+		{name: "stats", synopsis: "queue stats [-json]", summary: "count the messages in the queue and its dead-letter queue", run: statsCmd},
+		{name: "peek", synopsis: "queue peek [-n count] [-dlq] [-wait duration] [-json]", summary: "show messages without consuming them", run: peekCmd},
+	}},
+	{name: "dlq", synopsis: "dlq <redrive>", summary: "handle dead letters", sub: []command{
+		{name: "redrive", synopsis: "dlq redrive", summary: "move every dead letter back to the queue", run: redriveCmd},
+	}},
+	{name: "reconcile", synopsis: "reconcile [-json] [-requeue]", summary: "compare the ledger with the invoice API", run: reconcileCmd},
+	{name: "ledger", synopsis: "ledger [-id n] [-message-id id] [-status s] [-name text] [-tax-id digits] [-since date] [-until date] [-limit n] [-json]", summary: "list the invoices recorded in the ledger", run: ledgerCmd},
+}
+
+// dispatch finds the command named by args, walking down subcommands, and
+// runs it with a flag set that prints its usage.
+func dispatch(ctx context.Context, e *env, list []command, parent string, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		printCommands(os.Stderr, parent, list)
+		if len(args) == 0 && parent != "" {
+			return usagef("%s needs a subcommand", parent)
+		}
+		return nil
+	}
+
+	for _, cmd := range list {
+		if cmd.name != args[0] {
+			continue
+		}
+		name := strings.TrimSpace(parent + " " + cmd.name)
+		if len(cmd.sub) > 0 {
+			return dispatch(ctx, e, cmd.sub, name, args[1:])
+		}
+
+		flags := flag.NewFlagSet(name, flag.ContinueOnError)
+		flags.Usage = func() {
+			fmt.Fprintf(flags.Output(), "usage: project %s\n\n%s.\n", cmd.synopsis, capitalize(cmd.summary))
+			hasFlags := false
+			flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+			if hasFlags {
+				fmt.Fprintln(flags.Output(), "\nflags:")
+				flags.PrintDefaults()
+			}
+		}
+		return cmd.run(ctx, e, flags, args[1:])
+	}
+
+	printCommands(os.Stderr, parent, list)
+	return usagef("unknown command %q", strings.TrimSpace(parent+" "+args[0]))
+}
+
+func printCommands(out io.Writer, parent string, list []command) {
+	if parent == "" {
+		fmt.Fprintln(out, "usage: project [config flags] <command> [flags]")
+		fmt.Fprintln(out, "\nConfig flags override .env and the environment, see project -h. Without a command, run is used.")
+	} else {
+		fmt.Fprintf(out, "usage: project %s <command> [flags]\n", parent)
+	}
+
+	fmt.Fprintln(out, "\ncommands:")
+	for _, cmd := range list {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\nRun project %s<command> -h for the flags of a command.\n", strings.TrimPrefix(parent+" ", " "))
+}
+
+// parseFlags parses args and rejects positional arguments, which no command
+// takes.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err: err, printed: true}
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return usagef("unexpected argument %q", flags.Arg(0))
+	}
+
+	return nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// exitCode maps the error a command returned to the exit code of the
+// process and prints it.
+func exitCode(err error) int {
+	var usage usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOk
+	case errors.As(err, &usage):
+		if !usage.printed {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		return exitUsage
+	case errors.Is(err, errConfig):
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	case errors.Is(err, queue.ErrAuth):
+		fmt.Fprintln(os.Stderr, "AWS rejected the credentials, check AWS_PROFILE or AWS_ACCESS_KEY_ID:", err)
+		return exitUnavailable
+	case queue.Retryable(err):
+		fmt.Fprintln(os.Stderr, "SQS is still unavailable after retrying:", err)
+		return exitUnavailable
+	case errors.Is(err, queue.ErrValidation):
+		fmt.Fprintln(os.Stderr, "SQS rejected the request:", err)
+		return exitFailure
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"test/starkbank/project/requests"
+)
+
+// consumeCmd handles queued messages until it is stopped, without queueing
+// invoices of its own:
+//
+//	project consume [-for 10m]
+func consumeCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	duration := flags.Duration("for", 0, "stop after this long, 0 runs until SIGINT/SIGTERM")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *duration < 0 {
+		return usagef("-for can't be negative")
+	}
+
+	book, err := e.openLedger()
+	if err != nil {
+		return err
+	}
+	if err := e.setupQueue(ctx); err != nil {
+		return err
+	}
+	warnMemory(e)
+	e.serveMetrics(ctx)
+
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	invoices, err := requests.NewConsumer(e.cfg, e.queueUrl, e.queue, book)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errConfig, err)
+	}
+	fmt.Printf("Consuming %s.\n", e.cfg.Queue.Name)
+
+	err = invoices.Run(ctx)
+
+	stats := invoices.Stats()
+	fmt.Printf("Summary: %d received, %d processed, %d failed, %d left unacked.\n", stats.Received, stats.Processed, stats.Failed, stats.Unacked)
+	return err
+}
+
+// warnMemory tells commands that only produce or only consume that the
+// memory queue won't be shared with any other process.
+func warnMemory(e *env) {
+	if e.cfg.Queue.Driver == "memory" {
+		logger.Warn("QUEUE_DRIVER is memory, the queue lives and dies with this process")
+	}
+}
@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"context"
+	"time"
+)
+
+// ackInterval is the longest a handled message waits for its batch delete.
+const ackInterval = 200 * time.Millisecond
+
+// ack collects the receipt handles of handled messages and deletes them with
+// DeleteMessageBatch, flushing every 10 handles or every ackInterval. It
+// returns once handles is closed and the last batch is flushed.
+func (c *Consumer) ack(ctx context.Context, handles <-chan string) {
+	ticker := time.NewTicker(ackInterval)
+	defer ticker.Stop()
+
+	batch := make([]string, 0, 10)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		result, err := c.queue.DeleteMessageBatch(ctx, c.queueUrl, batch)
+		c.processed.Add(int64(len(result.Successful)))
+		if err == nil {
+			err = result.Err()
+		}
+		if err != nil {
+			logger.Warn("handled messages were not deleted", "count", len(result.Failed), "err", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case handle, ok := <-handles:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, handle)
+			if len(batch) == cap(batch) {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
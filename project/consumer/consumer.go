@@ -0,0 +1,301 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"test/starkbank/helpers"
+	"test/starkbank/logging"
+	"test/starkbank/project/metrics"
+	"test/starkbank/project/queue"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+var logger = logging.For("consumer")
+
+type (
+	// Handler processes one message. Returning nil acks (deletes) the
+	// message, any error leaves it on the queue to be redelivered.
+	Handler func(ctx context.Context, message types.Message) error
+
+	// retryAfter is a handler error caused by a dependency that is known to
+	// be down for a while, such as the invoice API behind an open circuit
+	// breaker.
+	retryAfter interface {
+		error
+		RetryAfter() time.Duration
+	}
+
+	Config struct {
+		Workers     int
+		MaxInFlight int
+		// Heartbeat is how often an in-flight message gets its visibility
+		// extended by VisibilityTimeout. Zero disables the heartbeat.
+		Heartbeat         time.Duration
+		VisibilityTimeout time.Duration
+		// DrainTimeout is how long handlers that already started get to
+		// finish once the consumer is asked to stop.
+		DrainTimeout time.Duration
+	}
+
+	// Stats counts what happened to the messages received by a consumer.
+	// Unacked are the ones received and never deleted, they will show up
+	// again once their visibility timeout expires.
+	Stats struct {
+		Received  int64
+		Processed int64
+		Failed    int64
+		Unacked   int64
+	}
+
+	// Consumer long-polls a queue and fans the messages out to a fixed set
+	// of workers. Every message group is pinned to one worker, so a group is
+	// processed in order while different groups run in parallel.
+	Consumer struct {
+		config   Config
+		queue    queue.Queue
+		queueUrl string
+		handler  Handler
+
+		inFlight  chan struct{}
+		received  atomic.Int64
+		processed atomic.Int64
+		failed    atomic.Int64
+
+		// pausedUntil is when polling may resume, in unix nanoseconds
+		pausedUntil atomic.Int64
+	}
+
+	job struct {
+		message types.Message
+		group   string
+		poll    uint64
+		// stop ends the visibility heartbeat started when the job was queued
+		stop func()
+	}
+)
+
+func DefaultConfig() Config {
+	return Config{
+		Workers:           4,
+		MaxInFlight:       16,
+		Heartbeat:         10 * time.Second,
+		VisibilityTimeout: 30 * time.Second,
+		DrainTimeout:      20 * time.Second,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Workers < 1 {
+		return fmt.Errorf("consumer needs at least 1 worker")
+	}
+	if c.MaxInFlight < 1 {
+		return fmt.Errorf("consumer max in flight must be at least 1")
+	}
+	if c.Heartbeat > 0 && c.VisibilityTimeout <= c.Heartbeat {
+		return fmt.Errorf("consumer visibility timeout must be longer than the heartbeat")
+	}
+	if c.VisibilityTimeout > 12*time.Hour {
+		return fmt.Errorf("consumer visibility timeout can't exceed 12h")
+	}
+	if c.DrainTimeout < 0 {
+		return fmt.Errorf("shutdown drain timeout can't be negative")
+	}
+
+	return nil
+}
+
+func New(config Config, q queue.Queue, queueUrl string, handler Handler) (*Consumer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Consumer{
+		config:   config,
+		queue:    q,
+		queueUrl: queueUrl,
+		handler:  handler,
+		inFlight: make(chan struct{}, config.MaxInFlight),
+	}, nil
+}
+
+// Run polls until ctx is done. Handlers that already started get
+// DrainTimeout to finish and be acked; messages still waiting for a worker
+// are left on the queue. Throttled or unavailable polls are retried with a
+// backoff, any other queue error stops the consumer and is returned. Polling
+// pauses while a handler reports its dependency down, see pause.
+func (c *Consumer) Run(ctx context.Context) error {
+	drainCtx, cancel := helpers.DrainContext(ctx, c.config.DrainTimeout)
+	defer cancel()
+
+	acks := make(chan string, c.config.MaxInFlight)
+	acked := make(chan struct{})
+	go func() {
+		defer close(acked)
+		c.ack(drainCtx, acks)
+	}()
+
+	workers := make([]chan job, c.config.Workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		workers[i] = make(chan job, c.config.MaxInFlight)
+		wg.Add(1)
+		go func(jobs <-chan job) {
+			defer wg.Done()
+			c.work(ctx, drainCtx, jobs, acks)
+		}(workers[i])
+	}
+
+	var poll uint64
+	var runErr error
+	backoff := time.Duration(0)
+	for ctx.Err() == nil {
+		if wait := time.Until(time.Unix(0, c.pausedUntil.Load())); wait > 0 {
+			logger.Warn("polling paused, a dependency is unavailable", "resume_in", wait)
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		messages, err := c.queue.GetMessages(ctx, c.queueUrl)
+		if err != nil {
+			if !queue.Retryable(err) {
+				runErr = err
+				break
+			}
+			backoff = min(max(2*backoff, time.Second), 30*time.Second)
+			logger.Warn("polling again", "backoff", backoff, "err", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		poll++
+		c.received.Add(int64(len(messages)))
+
+		for _, message := range messages {
+			select {
+			case c.inFlight <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+
+			group := messageGroup(message)
+			stop := c.heartbeat(drainCtx, *message.ReceiptHandle, *message.MessageId)
+			workers[pick(group, len(workers))] <- job{message: message, group: group, poll: poll, stop: stop}
+		}
+	}
+
+	for _, jobs := range workers {
+		close(jobs)
+	}
+	wg.Wait()
+	close(acks)
+	<-acked
+
+	return runErr
+}
+
+// work stops starting handlers once ctx is done, the ones already running
+// use drainCtx so they can still finish. Handled messages are passed on to
+// be deleted in batches.
+func (c *Consumer) work(ctx context.Context, drainCtx context.Context, jobs <-chan job, acks chan<- string) {
+	// failed remembers the last poll in which a group had a failure, later
+	// messages of that group from the same poll must not overtake it
+	failed := map[string]uint64{}
+
+	for j := range jobs {
+		if ctx.Err() != nil || failed[j.group] == j.poll {
+			j.stop()
+			<-c.inFlight
+			continue
+		}
+
+		ctx := logging.With(drainCtx, "message_id", *j.message.MessageId, "group", j.group)
+		metrics.HandlersInFlight.Inc()
+		err := c.handler(ctx, j.message)
+		metrics.HandlersInFlight.Dec()
+		j.stop()
+
+		var unavailable retryAfter
+		switch {
+		case errors.As(err, &unavailable):
+			failed[j.group] = j.poll
+			c.failed.Add(1)
+			metrics.MessagesFailed.WithLabelValues(metrics.QueueName(c.queueUrl), metrics.StageHandle).Inc()
+			wait := c.pause(ctx, *j.message.ReceiptHandle, unavailable.RetryAfter())
+			logger.WarnContext(ctx, "message put back until its dependency is available", "retry_in", wait, "err", err)
+		case err != nil:
+			failed[j.group] = j.poll
+			c.failed.Add(1)
+			metrics.MessagesFailed.WithLabelValues(metrics.QueueName(c.queueUrl), metrics.StageHandle).Inc()
+			logger.ErrorContext(ctx, "message failed", "err", err)
+		default:
+			acks <- *j.message.ReceiptHandle
+		}
+		<-c.inFlight
+	}
+}
+
+// pause stops polling for wait and hides the message for as long, so while
+// a dependency is down the messages waiting for it are received once per
+// wait instead of once per visibility timeout, and don't reach the
+// dead-letter queue before it is back. wait is rounded up to the next
+// second, the unit of visibility timeouts.
+func (c *Consumer) pause(ctx context.Context, handle string, wait time.Duration) time.Duration {
+	wait = wait.Truncate(time.Second) + time.Second
+
+	until := time.Now().Add(wait).UnixNano()
+	for {
+		current := c.pausedUntil.Load()
+		if current >= until || c.pausedUntil.CompareAndSwap(current, until) {
+			break
+		}
+	}
+
+	if err := c.queue.ChangeMessageVisibility(ctx, c.queueUrl, handle, wait); err != nil {
+		logger.WarnContext(ctx, "message not hidden during the pause", "err", err)
+	}
+
+	return wait
+}
+
+func (c *Consumer) Stats() Stats {
+	received := c.received.Load()
+	processed := c.processed.Load()
+
+	return Stats{
+		Received:  received,
+		Processed: processed,
+		Failed:    c.failed.Load(),
+		Unacked:   received - processed,
+	}
+}
+
+// messageGroup returns the FIFO group of the message, messages from
+// standard queues are spread by their own id.
+func messageGroup(message types.Message) string {
+	if group, ok := message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]; ok {
+		return group
+	}
+
+	return *message.MessageId
+}
+
+func pick(group string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(group))
+
+	return int(h.Sum32() % uint32(workers))
+}
@@ -0,0 +1,71 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"test/starkbank/logging"
+	"test/starkbank/project/queue"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// ErrRejected is returned for messages no handler accepts. They are left on
+// the queue like any other failure, so the redrive policy moves them to the
+// dead-letter queue instead of retrying them forever.
+var ErrRejected = errors.New("message rejected")
+
+type (
+	// EnvelopeHandler processes the body of one message type and version.
+	EnvelopeHandler func(ctx context.Context, envelope queue.Envelope) error
+
+	// Router dispatches messages to a handler by envelope type and schema
+	// version.
+	Router struct {
+		handlers map[string]map[int]EnvelopeHandler
+	}
+)
+
+func NewRouter() *Router {
+	return &Router{handlers: map[string]map[int]EnvelopeHandler{}}
+}
+
+// Handle registers handler for version of msgType, replacing any handler
+// registered before for the same pair.
+func (r *Router) Handle(msgType string, version int, handler EnvelopeHandler) *Router {
+	if r.handlers[msgType] == nil {
+		r.handlers[msgType] = map[int]EnvelopeHandler{}
+	}
+	r.handlers[msgType][version] = handler
+
+	return r
+}
+
+// Handler opens the envelope of every message and calls the matching
+// handler. Unknown types, unsupported versions and malformed envelopes fail
+// with ErrRejected before the body is ever decoded.
+func (r *Router) Handler() Handler {
+	return func(ctx context.Context, message types.Message) error {
+		envelope, err := queue.OpenEnvelope(message)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+
+		ctx = logging.With(ctx, "correlation_id", envelope.CorrelationId, "type", envelope.Type)
+
+		versions, ok := r.handlers[envelope.Type]
+		if !ok {
+			return fmt.Errorf("%w: unknown message type %q (correlation id %s)", ErrRejected, envelope.Type, envelope.CorrelationId)
+		}
+		handler, ok := versions[envelope.Version]
+		if !ok {
+			return fmt.Errorf("%w: unsupported %s schema version %d (correlation id %s)", ErrRejected, envelope.Type, envelope.Version, envelope.CorrelationId)
+		}
+
+		if err := handler(ctx, envelope); err != nil {
+			return fmt.Errorf("%s %s: %w", envelope.Type, envelope.CorrelationId, err)
+		}
+
+		return nil
+	}
+}
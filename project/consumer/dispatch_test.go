@@ -0,0 +1,66 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"test/starkbank/project/queue"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestRouter(t *testing.T) {
+	errHandler := errors.New("api down")
+	var called string
+
+	handler := NewRouter().
+		Handle(queue.TypeInvoiceCreate, 1, func(ctx context.Context, envelope queue.Envelope) error {
+			called = "invoice v1"
+			return nil
+		}).
+		Handle(queue.TypeInvoiceCreate, 2, func(ctx context.Context, envelope queue.Envelope) error {
+			called = "invoice v2"
+			return nil
+		}).
+		Handle(queue.TypeTransferCreate, 1, func(ctx context.Context, envelope queue.Envelope) error {
+			called = "transfer v1"
+			return errHandler
+		}).
+		Handler()
+
+	message := func(msgType string, version int) types.Message {
+		envelope := queue.NewEnvelope(msgType, version, []byte("{}"))
+		return types.Message{MessageId: aws.String("m1"), Body: aws.String("{}"), MessageAttributes: envelope.Attributes()}
+	}
+	malformed := message(queue.TypeInvoiceCreate, 1)
+	malformed.MessageAttributes[queue.AttributeVersion] = types.MessageAttributeValue{DataType: aws.String("Number"), StringValue: aws.String("x")}
+
+	tests := []struct {
+		name       string
+		message    types.Message
+		wantCalled string
+		wantErr    error
+	}{
+		{name: "invoice v1", message: message(queue.TypeInvoiceCreate, 1), wantCalled: "invoice v1"},
+		{name: "invoice v2", message: message(queue.TypeInvoiceCreate, 2), wantCalled: "invoice v2"},
+		{name: "legacy message", message: types.Message{MessageId: aws.String("m1"), Body: aws.String("{}")}, wantCalled: "invoice v1"},
+		{name: "handler error", message: message(queue.TypeTransferCreate, 1), wantCalled: "transfer v1", wantErr: errHandler},
+		{name: "unknown type", message: message("refund.create", 1), wantErr: ErrRejected},
+		{name: "unsupported version", message: message(queue.TypeTransferCreate, 3), wantErr: ErrRejected},
+		{name: "malformed envelope", message: malformed, wantErr: ErrRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = ""
+			err := handler(context.Background(), tt.message)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("handler() error = %v, want %v", err, tt.wantErr)
+			}
+			if called != tt.wantCalled {
+				t.Fatalf("called %q, want %q", called, tt.wantCalled)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package consumer
+
+import (
+	"context"
+	"time"
+)
+
+// heartbeat keeps a received message hidden, while it waits for a worker and
+// while its handler runs, by extending the visibility timeout every interval.
+// The returned stop func must be called once the handler is done, before the
+// message is deleted.
+func (c *Consumer) heartbeat(ctx context.Context, handle string, messageId string) (stop func()) {
+	if c.config.Heartbeat <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(c.config.Heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := c.queue.ChangeMessageVisibility(ctx, c.queueUrl, handle, c.config.VisibilityTimeout)
+				if err != nil {
+					if ctx.Err() == nil {
+						logger.Warn("heartbeat stopped", "message_id", messageId, "err", err)
+					}
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,178 @@
+package guard
+
+import (
+	"errors"
+	"sync"
+	"test/starkbank/project/metrics"
+	"time"
+)
+
+// ErrOpen is returned instead of making a call while the breaker is open,
+// wrapped in an *OpenError.
+var ErrOpen = errors.New("circuit breaker open")
+
+type (
+	State int
+
+	// OpenError is the error of a call refused by the breaker. It is
+	// ErrOpen for errors.Is.
+	OpenError struct {
+		// RetryIn is how long until the breaker lets a probe through, zero
+		// while a probe is already in flight.
+		RetryIn time.Duration
+	}
+
+	// Outcome is how a call let through by Allow ended.
+	Outcome int
+)
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+const (
+	// Success is a call the service answered.
+	Success Outcome = iota
+	// Failure is a server error or a timeout, it counts against the
+	// breaker.
+	Failure
+	// Abandoned is a call given up by its caller before the service
+	// answered. It tells nothing about the service, so it neither counts
+	// as a failure nor as a successful probe.
+	Abandoned
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "open"
+	}
+}
+
+// Breaker opens after threshold consecutive failures and refuses calls for
+// cooldown. It then lets probes calls through, one at a time: as many
+// successes in a row close it again, a failure opens it for another
+// cooldown.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	probes    int
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	successes int
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration, probes int) *Breaker {
+	metrics.BreakerState.WithLabelValues(name).Set(float64(Closed))
+
+	return &Breaker{name: name, threshold: threshold, cooldown: cooldown, probes: probes}
+}
+
+// Allow returns ErrOpen when the call must not be made, otherwise done must
+// be called with its outcome.
+func (b *Breaker) Allow() (done func(Outcome), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == Open {
+		if open := time.Since(b.openedAt); open < b.cooldown {
+			return nil, &OpenError{RetryIn: b.cooldown - open}
+		}
+		b.successes = 0
+		b.set(HalfOpen, "probing the api")
+	}
+	if b.state == HalfOpen {
+		if b.probing {
+			return nil, &OpenError{}
+		}
+		b.probing = true
+		return b.probeDone, nil
+	}
+
+	return b.done, nil
+}
+
+func (e *OpenError) Error() string {
+	return ErrOpen.Error()
+}
+
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// RetryAfter lets callers that don't know about the breaker, such as the
+// consumer, wait for it instead of failing again right away.
+func (e *OpenError) RetryAfter() time.Duration {
+	return e.RetryIn
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *Breaker) done(outcome Outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch outcome {
+	case Abandoned:
+		return
+	case Success:
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == Closed && b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.set(Open, "too many consecutive failures")
+	}
+}
+
+func (b *Breaker) probeDone(outcome Outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// an abandoned probe only frees the slot for the next one
+	b.probing = false
+	switch outcome {
+	case Abandoned:
+		return
+	case Failure:
+		b.openedAt = time.Now()
+		b.set(Open, "probe failed")
+		return
+	}
+
+	b.successes++
+	if b.successes >= b.probes {
+		b.failures = 0
+		b.set(Closed, "probes succeeded")
+	}
+}
+
+func (b *Breaker) set(state State, reason string) {
+	b.state = state
+	metrics.BreakerState.WithLabelValues(b.name).Set(float64(state))
+
+	attrs := []any{"breaker", b.name, "state", state.String(), "reason", reason}
+	if state == Open {
+		logger.Warn("circuit breaker opened", append(attrs, "failures", b.failures, "retry_in", b.cooldown)...)
+		return
+	}
+	logger.Info("circuit breaker "+state.String(), attrs...)
+}
@@ -0,0 +1,164 @@
+package guard
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const cooldown = 20 * time.Millisecond
+
+// step is one thing done to a breaker, followed by checking its state.
+type step struct {
+	// action is "call" (Allow, then done with outcome right away), "start"
+	// (Allow, holding done), "finish" (the held done with outcome),
+	// "refused" (Allow must fail with ErrOpen) or "cooldown" (sleep past
+	// it).
+	action  string
+	outcome Outcome
+	want    State
+}
+
+func TestBreaker(t *testing.T) {
+	opened := []step{
+		{action: "call", outcome: Failure, want: Closed},
+		{action: "call", outcome: Failure, want: Open},
+	}
+
+	tests := []struct {
+		name   string
+		probes int
+		steps  []step
+	}{
+		{
+			name:   "opens after threshold failures",
+			probes: 1,
+			steps: []step{
+				{action: "call", outcome: Failure, want: Closed},
+				{action: "call", outcome: Failure, want: Open},
+				{action: "refused", want: Open},
+			},
+		},
+		{
+			name:   "a success resets the failures",
+			probes: 1,
+			steps: []step{
+				{action: "call", outcome: Failure, want: Closed},
+				{action: "call", outcome: Success, want: Closed},
+				{action: "call", outcome: Failure, want: Closed},
+			},
+		},
+		{
+			name:   "abandoned calls don't count",
+			probes: 1,
+			steps: []step{
+				{action: "call", outcome: Failure, want: Closed},
+				{action: "call", outcome: Abandoned, want: Closed},
+				{action: "call", outcome: Abandoned, want: Closed},
+			},
+		},
+		{
+			name:   "half-open after the cooldown",
+			probes: 1,
+			steps: append(opened,
+				step{action: "cooldown", want: Open},
+				step{action: "start", want: HalfOpen},
+			),
+		},
+		{
+			name:   "one probe at a time",
+			probes: 1,
+			steps: append(opened,
+				step{action: "cooldown", want: Open},
+				step{action: "start", want: HalfOpen},
+				step{action: "refused", want: HalfOpen},
+			),
+		},
+		{
+			name:   "abandoned probe frees the slot",
+			probes: 1,
+			steps: append(opened,
+				step{action: "cooldown", want: Open},
+				step{action: "start", want: HalfOpen},
+				step{action: "finish", outcome: Abandoned, want: HalfOpen},
+				step{action: "start", want: HalfOpen},
+			),
+		},
+		{
+			name:   "failed probe reopens",
+			probes: 1,
+			steps: append(opened,
+				step{action: "cooldown", want: Open},
+				step{action: "call", outcome: Failure, want: Open},
+				step{action: "refused", want: Open},
+			),
+		},
+		{
+			name:   "successful probe closes",
+			probes: 1,
+			steps: append(opened,
+				step{action: "cooldown", want: Open},
+				step{action: "call", outcome: Success, want: Closed},
+				step{action: "call", outcome: Failure, want: Closed},
+			),
+		},
+		{
+			name:   "probes must all succeed",
+			probes: 2,
+			steps: append(opened,
+				step{action: "cooldown", want: Open},
+				step{action: "call", outcome: Success, want: HalfOpen},
+				step{action: "call", outcome: Success, want: Closed},
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker("test", 2, cooldown, tt.probes)
+			var held func(Outcome)
+
+			for i, s := range tt.steps {
+				switch s.action {
+				case "call", "start":
+					done, err := b.Allow()
+					if err != nil {
+						t.Fatalf("step %d: Allow() = %v", i, err)
+					}
+					if s.action == "start" {
+						held = done
+					} else {
+						done(s.outcome)
+					}
+				case "finish":
+					held(s.outcome)
+				case "refused":
+					if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+						t.Fatalf("step %d: Allow() = %v, want ErrOpen", i, err)
+					}
+				case "cooldown":
+					time.Sleep(cooldown + 5*time.Millisecond)
+				}
+
+				if got := b.State(); got != s.want {
+					t.Fatalf("step %d (%s): state %s, want %s", i, s.action, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenErrorRetryIn(t *testing.T) {
+	b := NewBreaker("test", 1, time.Minute, 1)
+	done, _ := b.Allow()
+	done(Failure)
+
+	_, err := b.Allow()
+	var open *OpenError
+	if !errors.As(err, &open) {
+		t.Fatalf("Allow() = %v, want an *OpenError", err)
+	}
+	if open.RetryAfter() <= 0 || open.RetryAfter() > time.Minute {
+		t.Fatalf("RetryAfter() = %s, want the rest of the cooldown", open.RetryAfter())
+	}
+}
@@ -0,0 +1,97 @@
+package guard
+
+import (
+	"context"
+	"fmt"
+	"test/starkbank/logging"
+	"time"
+)
+
+var logger = logging.For("guard")
+
+type (
+	Config struct {
+		// Rate is the calls per second allowed on average and Burst how many
+		// can go at once. Zero Rate turns rate limiting off.
+		Rate  float64
+		Burst int
+		// Failures is how many consecutive 5xx responses or timeouts open
+		// the breaker, zero turns it off. It stays open for Cooldown, then
+		// Probes calls in a row must succeed to close it.
+		Failures int
+		Cooldown time.Duration
+		Probes   int
+	}
+
+	// Guard rate limits the calls to a remote service and stops them while
+	// it keeps failing. A nil Guard lets every call through.
+	Guard struct {
+		limiter *Limiter
+		breaker *Breaker
+	}
+)
+
+func DefaultConfig() Config {
+	return Config{
+		Rate:     10,
+		Burst:    8,
+		Failures: 5,
+		Cooldown: 30 * time.Second,
+		Probes:   1,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Rate < 0 {
+		return fmt.Errorf("rate limit can't be negative")
+	}
+	if c.Rate > 0 && c.Burst < 1 {
+		return fmt.Errorf("rate limit burst must be at least 1")
+	}
+	if c.Failures < 0 {
+		return fmt.Errorf("breaker failures can't be negative")
+	}
+	if c.Failures > 0 && (c.Cooldown <= 0 || c.Probes < 1) {
+		return fmt.Errorf("breaker needs a positive cooldown and at least 1 probe")
+	}
+
+	return nil
+}
+
+// New returns the guard for the service called name, which labels its
+// logs and metrics.
+func New(name string, config Config) (*Guard, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	g := &Guard{}
+	if config.Rate > 0 {
+		g.limiter = NewLimiter(config.Rate, config.Burst)
+	}
+	if config.Failures > 0 {
+		g.breaker = NewBreaker(name, config.Failures, config.Cooldown, config.Probes)
+	}
+
+	return g, nil
+}
+
+// Allow waits for the rate limiter and checks the breaker. It fails with
+// ctx's error or ErrOpen, otherwise done must be called with the Outcome of
+// the call.
+func (g *Guard) Allow(ctx context.Context) (done func(Outcome), err error) {
+	if g == nil {
+		return func(Outcome) {}, nil
+	}
+
+	if g.limiter != nil {
+		if err := g.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if g.breaker == nil {
+		return func(Outcome) {}, nil
+	}
+
+	return g.breaker.Allow()
+}
@@ -0,0 +1,73 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		// spend tokens are taken, then idle passes before calls are timed
+		spend   int
+		idle    time.Duration
+		calls   int
+		atLeast time.Duration
+		atMost  time.Duration
+	}{
+		{name: "burst goes at once", rate: 10, burst: 3, calls: 3, atMost: 30 * time.Millisecond},
+		{name: "past the burst waits", rate: 20, burst: 2, calls: 4, atLeast: 90 * time.Millisecond, atMost: 150 * time.Millisecond},
+		{name: "tokens refill while idle", rate: 20, burst: 2, spend: 2, idle: 110 * time.Millisecond, calls: 2, atMost: 30 * time.Millisecond},
+		{name: "refill stops at the burst", rate: 50, burst: 1, spend: 1, idle: 100 * time.Millisecond, calls: 2, atLeast: 15 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.rate, tt.burst)
+			for range tt.spend {
+				l.Wait(context.Background())
+			}
+			time.Sleep(tt.idle)
+
+			start := time.Now()
+			for range tt.calls {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			elapsed := time.Since(start)
+
+			if elapsed < tt.atLeast || tt.atMost > 0 && elapsed > tt.atMost {
+				t.Fatalf("%d calls took %s, want between %s and %s", tt.calls, elapsed, tt.atLeast, tt.atMost)
+			}
+		})
+	}
+}
+
+func TestLimiterRefundsCancelledWait(t *testing.T) {
+	l := NewLimiter(10, 1)
+	l.Wait(context.Background())
+
+	// every cancelled wait hands its token back, so they don't push the
+	// next caller further back
+	for range 3 {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := l.Wait(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Wait() = %v, want the context error", err)
+		}
+	}
+
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("Wait() after cancelled waits took %s, want at most the 100ms of one token", elapsed)
+	}
+}
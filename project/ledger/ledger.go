@@ -0,0 +1,258 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"test/starkbank/money"
+	"time"
+)
+
+type (
+	// Entry is what the ledger knows about one created invoice.
+	Entry struct {
+		InvoiceId      int64       `json:"invoice_id"`
+		MessageId      string      `json:"message_id"`
+		IdempotencyKey string      `json:"idempotency_key,omitempty"`
+		CorrelationId  string      `json:"correlation_id,omitempty"`
+		Name           string      `json:"name"`
+		TaxId          string      `json:"tax_id"`
+		Amount         money.Money `json:"amount"`
+		Fee            money.Money `json:"fee"`
+		Status         string      `json:"status"`
+		SubmittedAt    time.Time   `json:"submitted_at"`
+		CreatedAt      time.Time   `json:"created_at"`
+		RecordedAt     time.Time   `json:"recorded_at"`
+		// RequeuedAt is set once reconciliation found the invoice missing on
+		// the API and queued it again, the new invoice gets its own entry.
+		RequeuedAt time.Time `json:"requeued_at,omitzero"`
+
+		// Transfer fields are set once the paid amount was transferred out.
+		TransferId     int64       `json:"transfer_id,omitempty"`
+		TransferAmount money.Money `json:"transfer_amount,omitempty"`
+		TransferredAt  time.Time   `json:"transferred_at,omitzero"`
+	}
+
+	// Filter selects entries in Query. Zero fields match everything.
+	Filter struct {
+		InvoiceId int64
+		MessageId string
+		Status    string
+		// Name matches any entry whose name contains it, ignoring case.
+		Name  string
+		TaxId string
+		// Since and Until bound CreatedAt, Until is exclusive.
+		Since time.Time
+		Until time.Time
+		Limit int
+	}
+
+	// Ledger is an append-only JSON lines file. An invoice recorded more than
+	// once keeps its last entry, that is how updates are written. The file
+	// is read once by Open and kept in memory, so only one process should
+	// write a ledger at a time.
+	Ledger struct {
+		path string
+		mu   sync.Mutex
+		// entries holds the latest entry per invoice in the order they were
+		// first recorded, index maps an invoice id to its position.
+		entries []Entry
+		index   map[int64]int
+	}
+)
+
+// Open reads the ledger at path, creating its directory if needed. The
+// file itself is created on the first Record.
+func Open(path string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating ledger directory: %w", err)
+	}
+
+	l := &Ledger{path: path, index: map[int64]int{}}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Record appends entry and syncs the file before returning, so an invoice
+// is never acked without being recorded. It replaces whatever was recorded
+// for the invoice before, use Update to change some fields only.
+func (l *Ledger) Record(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.record(entry)
+}
+
+// Update records the latest entry of an invoice as changed by update, which
+// gets a zero Entry with only InvoiceId set when the invoice was never
+// recorded. The read and the write happen under one lock, so handlers
+// updating different fields of the same invoice don't undo each other.
+func (l *Ledger) Update(invoiceId int64, update func(*Entry)) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := Entry{InvoiceId: invoiceId}
+	if i, ok := l.index[invoiceId]; ok {
+		entry = l.entries[i]
+	}
+	update(&entry)
+	entry.InvoiceId = invoiceId
+	entry.RecordedAt = time.Time{}
+
+	if err := l.record(entry); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// record writes entry, l.mu must be held.
+func (l *Ledger) record(entry Entry) error {
+	if entry.InvoiceId == 0 {
+		return fmt.Errorf("ledger entry without invoice id")
+	}
+	if entry.RecordedAt.IsZero() {
+		entry.RecordedAt = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening ledger: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("error writing ledger: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+
+	l.add(entry)
+	return nil
+}
+
+// Get returns the latest entry of an invoice, ok is false when it was never
+// recorded.
+func (l *Ledger) Get(invoiceId int64) (entry Entry, ok bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i, ok := l.index[invoiceId]
+	if !ok {
+		return Entry{}, false, nil
+	}
+
+	return l.entries[i], true, nil
+}
+
+// Query returns the latest entry of every invoice matching filter, in the
+// order the invoices were first recorded.
+func (l *Ledger) Query(filter Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	matched := []Entry{}
+	for _, entry := range l.entries {
+		if !filter.match(entry) {
+			continue
+		}
+		matched = append(matched, entry)
+		if filter.Limit > 0 && len(matched) == filter.Limit {
+			break
+		}
+	}
+
+	return matched, nil
+}
+
+// load folds the file into the latest entry per invoice. A last line without
+// a newline is a write cut short by a crash, whose invoice was never acked.
+// It is cut from the file, so the next record starts on a line of its own.
+func (l *Ledger) load() error {
+	data, err := os.ReadFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading ledger: %w", err)
+	}
+
+	if end := bytes.LastIndexByte(data, '\n') + 1; end < len(data) {
+		if err := os.Truncate(l.path, int64(end)); err != nil {
+			return fmt.Errorf("error dropping the unfinished last line of the ledger: %w", err)
+		}
+		data = data[:end]
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("ledger line %d: %w", n, err)
+		}
+		l.add(entry)
+	}
+
+	return scanner.Err()
+}
+
+// add makes entry the latest of its invoice, l.mu must be held or l not
+// shared yet.
+func (l *Ledger) add(entry Entry) {
+	if i, ok := l.index[entry.InvoiceId]; ok {
+		l.entries[i] = entry
+		return
+	}
+	l.index[entry.InvoiceId] = len(l.entries)
+	l.entries = append(l.entries, entry)
+}
+
+// Transferred reports whether the invoice amount was already paid out.
+func (e Entry) Transferred() bool {
+	return e.TransferId != 0
+}
+
+func (f Filter) match(entry Entry) bool {
+	switch {
+	case f.InvoiceId != 0 && entry.InvoiceId != f.InvoiceId:
+		return false
+	case f.MessageId != "" && entry.MessageId != f.MessageId:
+		return false
+	case f.Status != "" && !strings.EqualFold(entry.Status, f.Status):
+		return false
+	case f.Name != "" && !strings.Contains(strings.ToLower(entry.Name), strings.ToLower(f.Name)):
+		return false
+	case f.TaxId != "" && entry.TaxId != f.TaxId:
+		return false
+	case !f.Since.IsZero() && entry.CreatedAt.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.CreatedAt.Before(f.Until):
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,142 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func open(t *testing.T, path string) *Ledger {
+	t.Helper()
+
+	book, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return book
+}
+
+func ids(entries []Entry) []int64 {
+	out := make([]int64, len(entries))
+	for i, entry := range entries {
+		out[i] = entry.InvoiceId
+	}
+	return out
+}
+
+func TestOpenTruncatedLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "ledger.jsonl")
+	book := open(t, path)
+	for id := range int64(2) {
+		if err := book.Record(Entry{InvoiceId: id + 1, Name: "Shallan"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a crash in the middle of the third write
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"invoice_id":3,"na`)
+	file.Close()
+
+	book = open(t, path)
+	if _, ok, _ := book.Get(3); ok {
+		t.Fatal("the truncated entry was loaded")
+	}
+	if err := book.Record(Entry{InvoiceId: 4, Name: "Kaladin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the next record must not have been glued to the cut line
+	entries, err := open(t, path).Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(entries); !slices.Equal(got, []int64{1, 2, 4}) {
+		t.Fatalf("reopened ledger has invoices %v, want [1 2 4]", got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	book := open(t, path)
+	if err := book.Record(Entry{InvoiceId: 1, Name: "Shallan", Amount: 10000, Status: "CREATED"}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := book.Update(1, func(entry *Entry) {
+		entry.Status = "PAYED"
+		entry.TransferId = 7
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Shallan" || updated.Amount != 10000 || updated.Status != "PAYED" || updated.TransferId != 7 {
+		t.Fatalf("Update() = %+v, want the recorded fields with the status and transfer changed", updated)
+	}
+
+	created, err := book.Update(2, func(entry *Entry) { entry.Name = "Kaladin" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.InvoiceId != 2 || created.Name != "Kaladin" {
+		t.Fatalf("Update() of an unknown invoice = %+v", created)
+	}
+
+	reopened, ok, err := open(t, path).Get(1)
+	if err != nil || !ok {
+		t.Fatalf("Get(1) after reopening = %v, %v", ok, err)
+	}
+	if reopened.Name != "Shallan" || reopened.Status != "PAYED" || reopened.TransferId != 7 {
+		t.Fatalf("reopened entry = %+v, want the update kept", reopened)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	book := open(t, filepath.Join(t.TempDir(), "ledger.jsonl"))
+	for _, entry := range []Entry{
+		{InvoiceId: 1, Name: "Shallan Davar", TaxId: "1", Status: "PAYED", CreatedAt: day},
+		{InvoiceId: 2, Name: "Kaladin", TaxId: "2", Status: "CREATED", CreatedAt: day.Add(time.Hour)},
+		{InvoiceId: 3, Name: "Jasnah", TaxId: "1", Status: "PAYED", CreatedAt: day.Add(2 * time.Hour)},
+		// a later entry of invoice 2 replaces the first one
+		{InvoiceId: 2, Name: "Kaladin", TaxId: "2", Status: "PAYED", CreatedAt: day.Add(time.Hour)},
+	} {
+		if err := book.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int64
+	}{
+		{name: "everything", want: []int64{1, 2, 3}},
+		{name: "status ignores case", filter: Filter{Status: "payed"}, want: []int64{1, 2, 3}},
+		{name: "status of the latest entry", filter: Filter{Status: "CREATED"}, want: []int64{}},
+		{name: "since", filter: Filter{Since: day.Add(time.Hour)}, want: []int64{2, 3}},
+		{name: "until is exclusive", filter: Filter{Until: day.Add(2 * time.Hour)}, want: []int64{1, 2}},
+		{name: "invoice id", filter: Filter{InvoiceId: 3}, want: []int64{3}},
+		{name: "name contains", filter: Filter{Name: "davar"}, want: []int64{1}},
+		{name: "tax id", filter: Filter{TaxId: "1"}, want: []int64{1, 3}},
+		{name: "limit", filter: Filter{Limit: 2}, want: []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := book.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(entries); !slices.Equal(got, tt.want) {
+				t.Fatalf("Query(%+v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
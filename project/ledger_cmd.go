@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"test/starkbank/project/ledger"
+	"text/tabwriter"
+	"time"
+)
+
+// ledgerCmd lists the invoices recorded in the ledger:
+//
+//	project ledger [-id n] [-message-id id] [-status PAYED] [-name text] [-tax-id digits] [-since 2025-08-01] [-until 2025-08-02] [-limit n] [-json]
+func ledgerCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	var filter ledger.Filter
+	var since, until string
+	var asJson bool
+	flags.Int64Var(&filter.InvoiceId, "id", 0, "invoice id")
+	flags.StringVar(&filter.MessageId, "message-id", "", "source message id")
+	flags.StringVar(&filter.Status, "status", "", "invoice status, CREATED or PAYED")
+	flags.StringVar(&filter.Name, "name", "", "part of the payer name")
+	flags.StringVar(&filter.TaxId, "tax-id", "", "payer CPF or CNPJ, digits only")
+	flags.StringVar(&since, "since", "", "created at or after, a date or RFC 3339 time")
+	flags.StringVar(&until, "until", "", "created before, a date or RFC 3339 time")
+	flags.IntVar(&filter.Limit, "limit", 0, "list at most this many invoices")
+	flags.BoolVar(&asJson, "json", false, "print JSON lines instead of a table")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var err error
+	if filter.Since, err = parseTime(since); err != nil {
+		return usagef("invalid -since: %w", err)
+	}
+	if filter.Until, err = parseTime(until); err != nil {
+		return usagef("invalid -until: %w", err)
+	}
+
+	book, err := e.openLedger()
+	if err != nil {
+		return err
+	}
+	entries, err := book.Query(filter)
+	if err != nil {
+		return err
+	}
+
+	if asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tNAME\tTAX ID\tAMOUNT\tFEE\tCREATED AT\tMESSAGE ID")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.InvoiceId, e.Status, e.Name, e.TaxId, e.Amount, e.Fee, e.CreatedAt.Format(time.DateTime), e.MessageId)
+	}
+	fmt.Fprintf(w, "%d invoices\n", len(entries))
+
+	return w.Flush()
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
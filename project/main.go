@@ -2,31 +2,164 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
 	"test/starkbank/config"
+	"test/starkbank/logging"
+	"test/starkbank/money"
+	"test/starkbank/project/ledger"
+	"test/starkbank/project/metrics"
 	"test/starkbank/project/queue"
-	"test/starkbank/project/requests"
+	"test/starkbank/project/settings"
 
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 )
 
-var logFile = "../logs/project_error.txt"
+var logger = logging.For("project")
+
+// env is what commands share: the config and, once a command asks for it,
+// the queue.
+type env struct {
+	cfg settings.Config
+
+	queue    queue.Queue
+	queueUrl string
+	dlqUrl   string
+}
 
 func main() {
-	ctx := context.Background()
+	os.Exit(run())
+}
+
+func run() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	flags := config.RegisterFlags(flag.CommandLine, settings.Keys...)
+	flag.Usage = func() {
+		printCommands(flag.CommandLine.Output(), "", commands)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nconfig flags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	cfg, err := settings.Load(flags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return exitConfig
+	}
+
+	money.SetMode(cfg.Money)
+
+	logs, err := logging.Setup(cfg.Log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	defer logs.Close()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"run"}
+	}
+
+	err = dispatch(ctx, &env{cfg: cfg}, commands, "", args)
+	if err != nil {
+		logger.Debug("command failed", "command", args[0], "err", err)
+	}
+
+	return exitCode(err)
+}
+
+// setupQueue connects to the broker and gets or creates the queue and its
+// dead-letter queue.
+func (e *env) setupQueue(ctx context.Context) error {
+	client, err := queueClient(ctx, e.cfg.Config)
+	if err != nil {
+		return err
+	}
 
-	cfg := config.ConfigAWS(ctx)
+	queueUrl, err := getOrCreateQueue(ctx, client, e.cfg.Queue.Name)
+	if err != nil {
+		return err
+	}
+	dlqUrl, err := getOrCreateQueue(ctx, client, queue.DeadLetterName(e.cfg.Queue.Name))
+	if err != nil {
+		return err
+	}
 
-	sqsClient := sqs.NewFromConfig(cfg)
+	err = queue.Retry(ctx, 5, func() error {
+		return client.AttachDeadLetterQueue(ctx, queueUrl, dlqUrl, e.cfg.Queue.MaxReceiveCount)
+	})
+	if err != nil {
+		return err
+	}
+
+	e.queue, e.queueUrl, e.dlqUrl = client, queueUrl, dlqUrl
+	return nil
+}
 
-	newClient := queue.SqsAction(sqsClient)
-	queueName := "invoices.fifo"
+// getOrCreateQueue looks the queue up and only creates it when SQS says it
+// does not exist. Throttling and outages are retried, anything else (bad
+// credentials, invalid names) is returned.
+func getOrCreateQueue(ctx context.Context, client queue.Queue, queueName string) (string, error) {
 	var queueUrl string
+	err := queue.Retry(ctx, 5, func() error {
+		var err error
+		queueUrl, err = client.GetOrCreateQueue(ctx, queueName, true)
+		return err
+	})
+
+	return queueUrl, err
+}
+
+// openLedger opens the ledger at LEDGER_PATH, only the commands that use
+// the ledger need one.
+func (e *env) openLedger() (*ledger.Ledger, error) {
+	path, err := e.cfg.LedgerFile()
+	if err != nil {
+		return nil, fmt.Errorf("%w: ledger: %w", errConfig, err)
+	}
+
+	book, err := ledger.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errConfig, err)
+	}
+
+	return book, nil
+}
+
+// serveMetrics serves /metrics on METRICS_ADDR until ctx is done, if it is
+// set.
+func (e *env) serveMetrics(ctx context.Context) {
+	addr := e.cfg.MetricsAddr
+	if addr == "" {
+		return
+	}
+
+	go func() {
+		if err := metrics.Serve(ctx, addr); err != nil {
+			logger.Error("metrics server stopped", "err", err)
+		}
+	}()
+	fmt.Printf("Serving metrics on %s/metrics.\n", addr)
+}
+
+// queueClient picks the broker from QUEUE_DRIVER, so the pipeline can run
+// without AWS credentials by setting it to "memory".
+func queueClient(ctx context.Context, cfg config.Config) (queue.Queue, error) {
+	if cfg.Queue.Driver == "memory" {
+		return queue.NewMemoryQueue(), nil
+	}
 
-	queueUrl = newClient.GetQueue(ctx, queueName)
-	if queueUrl == "" {
-		queueUrl = newClient.CreateSqsQueue(ctx, queueName, true)
+	awsCfg, err := config.ConfigAWS(ctx, cfg.AWS)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errConfig, err)
 	}
 
-	requests.CreateInvoice(queueUrl, newClient)
+	sqsClient := sqs.NewFromConfig(awsCfg)
 
+	return queue.SqsAction(sqsClient), nil
 }
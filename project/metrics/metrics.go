@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "starkbank"
+
+// Stages a message can fail at, the stage label of MessagesFailed.
+const (
+	StageSend    = "send"
+	StageReceive = "receive"
+	StageDelete  = "delete"
+	StageHandle  = "handle"
+)
+
+var (
+	MessagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "messages_sent_total",
+		Help:      "Messages accepted by the queue.",
+	}, []string{"queue"})
+
+	MessagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "messages_received_total",
+		Help:      "Messages received from the queue.",
+	}, []string{"queue"})
+
+	MessagesDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "messages_deleted_total",
+		Help:      "Messages deleted (acked) from the queue.",
+	}, []string{"queue"})
+
+	MessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "messages_failed_total",
+		Help:      "Messages that failed to be sent, received, deleted or handled.",
+	}, []string{"queue", "stage"})
+
+	SendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "send_duration_seconds",
+		Help:      "Time taken by send calls, single or batched.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"queue"})
+
+	// ReceiveDuration includes the long-poll wait, so it goes up to 20s.
+	ReceiveDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "queue",
+		Name:      "receive_duration_seconds",
+		Help:      "Time taken by receive calls, long-poll wait included.",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 2.5, 5, 10, 15, 20, 25},
+	}, []string{"queue"})
+
+	ApiDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "invoice_api",
+		Name:      "request_duration_seconds",
+		Help:      "Invoice API call latency by endpoint and status code, code is \"error\" when no response came back.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "code"})
+
+	// BreakerState is 0 while a breaker is closed, 1 while it is half-open
+	// and 2 while it is open.
+	BreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "invoice_api",
+		Name:      "breaker_state",
+		Help:      "Circuit breaker state: 0 closed, 1 half-open, 2 open.",
+	}, []string{"breaker"})
+
+	HandlersInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "handlers_in_flight",
+		Help:      "Message handlers currently running.",
+	})
+
+	LastTick = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last scheduled run that queued its whole batch.",
+	})
+)
+
+// QueueName turns a queue url into the queue label, the last segment of
+// the path for SQS and the name for memory:// urls.
+func QueueName(queueUrl string) string {
+	return queueUrl[strings.LastIndex(queueUrl, "/")+1:]
+}
+
+// Serve exposes /metrics on addr until ctx is done.
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	})
+	defer stop()
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
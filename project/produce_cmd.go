@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"test/starkbank/generator"
+	"test/starkbank/project/requests"
+	"test/starkbank/taxid"
+	"time"
+)
+
+// produceCmd queues invoices without consuming them, either generated or
+// read from a file:
+//
+//	project produce -n 20
+//	project produce -file invoices.jsonl -group import-1
+func produceCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	count := flags.Int("n", 0, "generate and queue this many invoices")
+	file := flags.String("file", "", "queue the invoices in this file, a JSON array or one object per line, - for stdin")
+	group := flags.String("group", "", "message group of the invoices, sending the same invoices in the same group twice within 5 minutes queues them once (default produce-<time>)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if (*count != 0) == (*file != "") {
+		return usagef("pass either -n or -file")
+	}
+	if *count < 0 {
+		return usagef("-n can't be negative")
+	}
+	if *group == "" {
+		*group = "produce-" + time.Now().UTC().Format("20060102T150405")
+	}
+
+	var invoices []requests.Invoice
+	if *file != "" {
+		var err error
+		if invoices, err = readInvoices(*file); err != nil {
+			return usageError{err: err}
+		}
+	} else {
+		gen := generator.FromConfig(e.cfg.Generator)
+		fmt.Printf("Generating invoices with seed %d.\n", gen.Seed)
+		for range *count {
+			invoices = append(invoices, requests.Invoice{Amount: gen.Amount(), Name: gen.Name(), TaxId: gen.TaxId()})
+		}
+	}
+
+	if err := e.setupQueue(ctx); err != nil {
+		return err
+	}
+	warnMemory(e)
+
+	sent, err := requests.SendInvoices(ctx, invoices, *group, e.queue, e.queueUrl)
+	fmt.Printf("%d of %d invoices queued in group %s.\n", sent, len(invoices), *group)
+	return err
+}
+
+// readInvoices reads a JSON array of invoices, or one invoice per line, from
+// path, stdin when it is "-", and checks every one of them.
+func readInvoices(path string) ([]requests.Invoice, error) {
+	in := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	decoder := json.NewDecoder(in)
+	var invoices []requests.Invoice
+	for {
+		var next json.RawMessage
+		err := decoder.Decode(&next)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if len(next) > 0 && next[0] == '[' {
+			var list []requests.Invoice
+			if err := json.Unmarshal(next, &list); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			invoices = append(invoices, list...)
+			continue
+		}
+		var invoice requests.Invoice
+		if err := json.Unmarshal(next, &invoice); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		invoices = append(invoices, invoice)
+	}
+
+	if len(invoices) == 0 {
+		return nil, fmt.Errorf("%s has no invoices", path)
+	}
+	for i, invoice := range invoices {
+		switch {
+		case invoice.Name == "":
+			return nil, fmt.Errorf("%s: invoice %d has no name", path, i+1)
+		case invoice.Amount <= 0:
+			return nil, fmt.Errorf("%s: invoice %d has amount %s, it must be positive", path, i+1, invoice.Amount)
+		case !taxid.Valid(invoice.TaxId):
+			return nil, fmt.Errorf("%s: invoice %d has an invalid tax id %q", path, i+1, invoice.TaxId)
+		}
+	}
+
+	return invoices, nil
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"test/starkbank/broker"
+	"test/starkbank/project/metrics"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SendMessageBatch sends the messages in chunks of 10. Message ids must be
+// unique within the call.
+func (actor SqsActions) SendMessageBatch(ctx context.Context, queueUrl string, messages []BatchMessage) (BatchResult, error) {
+	ids, groups := broker.BatchIds(messages)
+
+	name := metrics.QueueName(queueUrl)
+	start := time.Now()
+	defer func() {
+		metrics.SendDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}()
+
+	result, err := broker.RunBatches(ctx, ids, groups, func(ctx context.Context, chunk []int) (map[int]broker.BatchFailure, error) {
+		entries := make([]types.SendMessageBatchRequestEntry, len(chunk))
+		for n, i := range chunk {
+			entries[n] = types.SendMessageBatchRequestEntry{
+				Id:                     aws.String(strconv.Itoa(i)),
+				MessageBody:            aws.String(string(messages[i].Body)),
+				MessageAttributes:      messages[i].Attributes,
+				MessageGroupId:         messages[i].Group,
+				MessageDeduplicationId: messages[i].DupId,
+			}
+		}
+
+		res, err := actor.SqsClient.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: &queueUrl,
+			Entries:  entries,
+		})
+		if err != nil {
+			return nil, classify("send message batch", err)
+		}
+
+		return batchFailures(res.Failed), nil
+	})
+	metrics.MessagesSent.WithLabelValues(name).Add(float64(len(result.Successful)))
+	metrics.MessagesFailed.WithLabelValues(name, metrics.StageSend).Add(float64(len(result.Failed)))
+
+	return result, err
+}
+
+func (actor SqsActions) DeleteMessageBatch(ctx context.Context, queueUrl string, handles []string) (BatchResult, error) {
+	result, err := broker.RunBatches(ctx, handles, nil, func(ctx context.Context, chunk []int) (map[int]broker.BatchFailure, error) {
+		entries := make([]types.DeleteMessageBatchRequestEntry, len(chunk))
+		for n, i := range chunk {
+			entries[n] = types.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(strconv.Itoa(i)),
+				ReceiptHandle: aws.String(handles[i]),
+			}
+		}
+
+		res, err := actor.SqsClient.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+			QueueUrl: &queueUrl,
+			Entries:  entries,
+		})
+		if err != nil {
+			return nil, classify("delete message batch", err)
+		}
+
+		return batchFailures(res.Failed), nil
+	})
+	name := metrics.QueueName(queueUrl)
+	metrics.MessagesDeleted.WithLabelValues(name).Add(float64(len(result.Successful)))
+	metrics.MessagesFailed.WithLabelValues(name, metrics.StageDelete).Add(float64(len(result.Failed)))
+
+	return result, err
+}
+
+func batchFailures(entries []types.BatchResultErrorEntry) map[int]broker.BatchFailure {
+	failures := make(map[int]broker.BatchFailure, len(entries))
+	for _, entry := range entries {
+		i, err := strconv.Atoi(aws.ToString(entry.Id))
+		if err != nil {
+			continue
+		}
+		failures[i] = broker.BatchFailure{
+			Code:        aws.ToString(entry.Code),
+			Message:     aws.ToString(entry.Message),
+			SenderFault: entry.SenderFault,
+		}
+	}
+
+	return failures
+}
@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// DeadLetterName returns the dead-letter queue name for queueName, keeping
+// the .fifo suffix SQS requires: invoices.fifo becomes invoices-dlq.fifo.
+func DeadLetterName(queueName string) string {
+	if base, ok := strings.CutSuffix(queueName, ".fifo"); ok {
+		return base + "-dlq.fifo"
+	}
+
+	return queueName + "-dlq"
+}
+
+// Redrive moves every message from the dead-letter queue back to the source
+// queue, keeping its message group and attributes, and returns how many were
+// moved. A message is only deleted from the dead-letter queue once it was
+// sent.
+func Redrive(ctx context.Context, q Queue, dlqUrl string, queueUrl string) (int, error) {
+	moved := 0
+	for ctx.Err() == nil {
+		messages, err := q.GetMessages(ctx, dlqUrl)
+		if err != nil {
+			return moved, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, message := range messages {
+			var group *string
+			if g, ok := message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]; ok {
+				group = &g
+			}
+			// the original id may still be inside the 5 minute dedup window
+			dupId := "redrive-" + *message.MessageId
+
+			if err := q.SendMessage(ctx, queueUrl, []byte(*message.Body), message.MessageAttributes, group, &dupId); err != nil {
+				return moved, err
+			}
+			if err := q.DeleteMessage(ctx, dlqUrl, *message.ReceiptHandle); err != nil {
+				return moved, err
+			}
+			moved++
+		}
+	}
+
+	return moved, ctx.Err()
+}
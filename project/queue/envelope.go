@@ -0,0 +1,138 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Message types carried in the envelope.
+const (
+	TypeInvoiceCreate  = "invoice.create"
+	TypeTransferCreate = "transfer.create"
+)
+
+// Message attribute names used to carry the envelope over SQS.
+const (
+	AttributeType          = "type"
+	AttributeVersion       = "schema_version"
+	AttributeCorrelationId = "correlation_id"
+	AttributeProducedAt    = "produced_at"
+	AttributeTraceParent   = "traceparent"
+	AttributeIdempotency   = "idempotency_key"
+)
+
+var ErrMalformedEnvelope = errors.New("malformed message envelope")
+
+// Envelope wraps a message body with the metadata consumers need to route
+// it. Everything but the body travels as message attributes, so the body
+// stays the bare payload.
+type Envelope struct {
+	Type          string
+	Version       int
+	CorrelationId string
+	ProducedAt    time.Time
+	// TraceParent is a W3C trace context header value.
+	TraceParent string
+	// IdempotencyKey identifies the operation across redeliveries and
+	// redrives, downstream APIs use it to avoid doing it twice.
+	IdempotencyKey string
+	Body           []byte
+
+	// MessageId is only set on received envelopes.
+	MessageId string
+}
+
+// NewEnvelope stamps body with a fresh correlation id, the current time and
+// a new trace.
+func NewEnvelope(msgType string, version int, body []byte) Envelope {
+	return Envelope{
+		Type:          msgType,
+		Version:       version,
+		CorrelationId: newId(),
+		ProducedAt:    time.Now().UTC(),
+		TraceParent:   "00-" + newId() + "-" + newId()[:16] + "-01",
+		Body:          body,
+	}
+}
+
+func (e Envelope) Attributes() map[string]types.MessageAttributeValue {
+	attributes := map[string]types.MessageAttributeValue{
+		AttributeType:          stringAttribute(e.Type),
+		AttributeVersion:       {DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(e.Version))},
+		AttributeCorrelationId: stringAttribute(e.CorrelationId),
+		AttributeProducedAt:    stringAttribute(e.ProducedAt.Format(time.RFC3339Nano)),
+	}
+	if e.TraceParent != "" {
+		attributes[AttributeTraceParent] = stringAttribute(e.TraceParent)
+	}
+	if e.IdempotencyKey != "" {
+		attributes[AttributeIdempotency] = stringAttribute(e.IdempotencyKey)
+	}
+
+	return attributes
+}
+
+// OpenEnvelope reads the envelope back from a received message. Messages
+// sent before the envelope existed carry no type and are read as version 1
+// invoices, which is all the producer used to send. Without an idempotency
+// key the deduplication id, or else the message id, is used instead.
+func OpenEnvelope(message types.Message) (Envelope, error) {
+	attributes := message.MessageAttributes
+	envelope := Envelope{
+		Body:      []byte(aws.ToString(message.Body)),
+		MessageId: aws.ToString(message.MessageId),
+	}
+
+	envelope.IdempotencyKey = aws.ToString(attributes[AttributeIdempotency].StringValue)
+	if envelope.IdempotencyKey == "" {
+		envelope.IdempotencyKey = message.Attributes[string(types.MessageSystemAttributeNameMessageDeduplicationId)]
+	}
+	if envelope.IdempotencyKey == "" {
+		envelope.IdempotencyKey = aws.ToString(message.MessageId)
+	}
+
+	msgType, ok := attributes[AttributeType]
+	if !ok {
+		envelope.Type = TypeInvoiceCreate
+		envelope.Version = 1
+		envelope.CorrelationId = aws.ToString(message.MessageId)
+		return envelope, nil
+	}
+	envelope.Type = aws.ToString(msgType.StringValue)
+
+	version, err := strconv.Atoi(aws.ToString(attributes[AttributeVersion].StringValue))
+	if err != nil {
+		return Envelope{}, fmt.Errorf("%w: schema version: %v", ErrMalformedEnvelope, err)
+	}
+	envelope.Version = version
+
+	envelope.CorrelationId = aws.ToString(attributes[AttributeCorrelationId].StringValue)
+	envelope.TraceParent = aws.ToString(attributes[AttributeTraceParent].StringValue)
+
+	if producedAt := aws.ToString(attributes[AttributeProducedAt].StringValue); producedAt != "" {
+		envelope.ProducedAt, err = time.Parse(time.RFC3339Nano, producedAt)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("%w: produced at: %v", ErrMalformedEnvelope, err)
+		}
+	}
+
+	return envelope, nil
+}
+
+func stringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+// newId returns 16 random bytes in hex, the size of a trace id.
+func newId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
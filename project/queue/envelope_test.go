@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	sent := NewEnvelope(TypeTransferCreate, 2, []byte(`{"invoice_id":1}`))
+	sent.IdempotencyKey = "transfer-1"
+
+	got, err := OpenEnvelope(types.Message{
+		MessageId:         aws.String("m1"),
+		Body:              aws.String(string(sent.Body)),
+		MessageAttributes: sent.Attributes(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent.MessageId = "m1"
+	if got.Type != sent.Type || got.Version != sent.Version || got.CorrelationId != sent.CorrelationId ||
+		got.TraceParent != sent.TraceParent || got.IdempotencyKey != sent.IdempotencyKey ||
+		string(got.Body) != string(sent.Body) || got.MessageId != sent.MessageId || !got.ProducedAt.Equal(sent.ProducedAt) {
+		t.Fatalf("OpenEnvelope() = %+v, want %+v", got, sent)
+	}
+}
+
+func TestOpenEnvelope(t *testing.T) {
+	attributes := func(change func(map[string]types.MessageAttributeValue)) map[string]types.MessageAttributeValue {
+		envelope := NewEnvelope(TypeInvoiceCreate, 1, nil)
+		a := envelope.Attributes()
+		change(a)
+		return a
+	}
+
+	tests := []struct {
+		name       string
+		message    types.Message
+		wantType   string
+		wantKey    string
+		wantErr    error
+		wantLegacy bool
+	}{
+		{
+			name:       "legacy message without attributes",
+			message:    types.Message{MessageId: aws.String("m1"), Body: aws.String("{}")},
+			wantType:   TypeInvoiceCreate,
+			wantKey:    "m1",
+			wantLegacy: true,
+		},
+		{
+			name: "deduplication id as idempotency key",
+			message: types.Message{
+				MessageId:  aws.String("m1"),
+				Attributes: map[string]string{string(types.MessageSystemAttributeNameMessageDeduplicationId): "dup-1"},
+			},
+			wantType:   TypeInvoiceCreate,
+			wantKey:    "dup-1",
+			wantLegacy: true,
+		},
+		{
+			name: "idempotency attribute wins",
+			message: types.Message{
+				MessageId:  aws.String("m1"),
+				Attributes: map[string]string{string(types.MessageSystemAttributeNameMessageDeduplicationId): "dup-1"},
+				MessageAttributes: attributes(func(a map[string]types.MessageAttributeValue) {
+					a[AttributeIdempotency] = stringAttribute("key-1")
+				}),
+			},
+			wantType: TypeInvoiceCreate,
+			wantKey:  "key-1",
+		},
+		{
+			name: "bad version",
+			message: types.Message{MessageId: aws.String("m1"), MessageAttributes: attributes(func(a map[string]types.MessageAttributeValue) {
+				a[AttributeVersion] = stringAttribute("one")
+			})},
+			wantErr: ErrMalformedEnvelope,
+		},
+		{
+			name: "bad produced at",
+			message: types.Message{MessageId: aws.String("m1"), MessageAttributes: attributes(func(a map[string]types.MessageAttributeValue) {
+				a[AttributeProducedAt] = stringAttribute("yesterday")
+			})},
+			wantErr: ErrMalformedEnvelope,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpenEnvelope(tt.message)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("OpenEnvelope() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type != tt.wantType || got.IdempotencyKey != tt.wantKey {
+				t.Fatalf("OpenEnvelope() = type %q key %q, want %q %q", got.Type, got.IdempotencyKey, tt.wantType, tt.wantKey)
+			}
+			if tt.wantLegacy && (got.Version != 1 || got.CorrelationId != "m1" || !got.ProducedAt.Equal(time.Time{})) {
+				t.Fatalf("legacy message read as %+v", got)
+			}
+		})
+	}
+}
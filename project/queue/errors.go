@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"net"
+	"test/starkbank/broker"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go"
+)
+
+// The error kinds and *Error are shared with MemoryQueue in broker.
+var (
+	ErrNotFound    = broker.ErrNotFound
+	ErrThrottled   = broker.ErrThrottled
+	ErrAuth        = broker.ErrAuth
+	ErrValidation  = broker.ErrValidation
+	ErrUnavailable = broker.ErrUnavailable
+)
+
+type Error = broker.Error
+
+var errorCodes = map[string]error{
+	"QueueDoesNotExist":                       ErrNotFound,
+	"AWS.SimpleQueueService.NonExistentQueue": ErrNotFound,
+	"ResourceNotFoundException":               ErrNotFound,
+	"QueueDeletedRecently":                    ErrNotFound,
+	"RequestThrottled":                        ErrThrottled,
+	"ThrottlingException":                     ErrThrottled,
+	"Throttling":                              ErrThrottled,
+	"OverLimit":                               ErrThrottled,
+	"KmsThrottled":                            ErrThrottled,
+	"AccessDenied":                            ErrAuth,
+	"AccessDeniedException":                   ErrAuth,
+	"InvalidClientTokenId":                    ErrAuth,
+	"UnrecognizedClientException":             ErrAuth,
+	"InvalidSecurity":                         ErrAuth,
+	"ExpiredToken":                            ErrAuth,
+	"ExpiredTokenException":                   ErrAuth,
+	"SignatureDoesNotMatch":                   ErrAuth,
+	"MissingAuthenticationToken":              ErrAuth,
+	"KmsAccessDenied":                         ErrAuth,
+	"InvalidParameterValue":                   ErrValidation,
+	"MissingParameter":                        ErrValidation,
+	"ValidationError":                         ErrValidation,
+	"ValidationException":                     ErrValidation,
+	"InvalidAttributeName":                    ErrValidation,
+	"InvalidAttributeValue":                   ErrValidation,
+	"InvalidMessageContents":                  ErrValidation,
+	"InvalidAddress":                          ErrValidation,
+	"InvalidIdFormat":                         ErrValidation,
+	"ReceiptHandleIsInvalid":                  ErrValidation,
+	"MessageNotInflight":                      ErrValidation,
+	"QueueNameExists":                         ErrValidation,
+	"UnsupportedOperation":                    ErrValidation,
+	"InvalidBatchEntryId":                     ErrValidation,
+	"BatchEntryIdsNotDistinct":                ErrValidation,
+	"BatchRequestTooLong":                     ErrValidation,
+	"EmptyBatchRequest":                       ErrValidation,
+	"TooManyEntriesInBatchRequest":            ErrValidation,
+	"PurgeQueueInProgress":                    ErrThrottled,
+	"ServiceUnavailable":                      ErrUnavailable,
+	"InternalFailure":                         ErrUnavailable,
+	"InternalError":                           ErrUnavailable,
+}
+
+// classify wraps an AWS SDK error into an *Error with the matching kind.
+func classify(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Op: op, Kind: kindOf(err), Err: err}
+}
+
+func kindOf(err error) error {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if kind, ok := errorCodes[apiErr.ErrorCode()]; ok {
+			return kind
+		}
+		if apiErr.ErrorFault() == smithy.FaultServer {
+			return ErrUnavailable
+		}
+	}
+
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) {
+		switch code := status.HTTPStatusCode(); {
+		case code == 401 || code == 403:
+			return ErrAuth
+		case code == 404:
+			return ErrNotFound
+		case code == 429:
+			return ErrThrottled
+		case code >= 500:
+			return ErrUnavailable
+		case code >= 400:
+			return ErrValidation
+		}
+	}
+
+	// credentials that can't be resolved fail while signing, before the
+	// request reaches the API
+	var signErr *v4.SigningError
+	if errors.As(err, &signErr) {
+		return ErrAuth
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrUnavailable
+	}
+
+	return nil
+}
+
+// Retryable reports whether trying the same call again may succeed.
+func Retryable(err error) bool {
+	return broker.Retryable(err)
+}
+
+// Retry calls fn until it succeeds, returns an error that is not Retryable or
+// attempts run out, backing off exponentially from 200ms between attempts.
+func Retry(ctx context.Context, attempts int, fn func() error) error {
+	wait := 200 * time.Millisecond
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil || !Retryable(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+
+	return err
+}
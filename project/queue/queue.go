@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"context"
+	"test/starkbank/broker"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// The batch types, Stats and MemoryQueue live in broker, which the mocked
+// SQS server shares without linking the pipeline.
+type (
+	BatchMessage = broker.BatchMessage
+	BatchFailure = broker.BatchFailure
+	BatchResult  = broker.BatchResult
+	Stats        = broker.Stats
+	MemoryQueue  = broker.MemoryQueue
+)
+
+func NewMemoryQueue() *MemoryQueue {
+	return broker.NewMemoryQueue()
+}
+
+// Queue is the set of operations the pipeline needs from a message broker.
+// SqsActions talks to AWS, MemoryQueue keeps everything in process. Errors
+// are *Error values whose kind (ErrNotFound, ErrThrottled, ...) can be
+// checked with errors.Is.
+type Queue interface {
+	CreateSqsQueue(ctx context.Context, queueName string, isFifo bool) (string, error)
+	GetQueue(ctx context.Context, queueName string) (string, error)
+	GetOrCreateQueue(ctx context.Context, queueName string, isFifo bool) (string, error)
+	SendMessage(ctx context.Context, queueUrl string, message []byte, attributes map[string]types.MessageAttributeValue, group *string, dupId *string) error
+	SendMessageBatch(ctx context.Context, queueUrl string, messages []BatchMessage) (BatchResult, error)
+	GetMessages(ctx context.Context, queueUrl string) ([]types.Message, error)
+	PurgeQueue(ctx context.Context, queueUrl string) error
+	DeleteMessage(ctx context.Context, queueUrl string, handle string) error
+	DeleteMessageBatch(ctx context.Context, queueUrl string, handles []string) (BatchResult, error)
+	ChangeMessageVisibility(ctx context.Context, queueUrl string, handle string, timeout time.Duration) error
+	AttachDeadLetterQueue(ctx context.Context, queueUrl string, dlqUrl string, maxReceiveCount int) error
+	Stats(ctx context.Context, queueUrl string) (Stats, error)
+}
+
+var (
+	_ Queue = SqsActions{}
+	_ Queue = (*MemoryQueue)(nil)
+)
@@ -2,29 +2,31 @@ package queue
 
 import (
 	"context"
-	"log"
-	"os"
-	"test/starkbank/helpers"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"test/starkbank/logging"
+	"test/starkbank/project/metrics"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
-var logFile = "../logs/sqs_errors.txt"
+var logger = logging.For("queue")
 
 type SqsActions struct {
 	SqsClient *sqs.Client
 }
 
-
 func SqsAction(sqsCliet *sqs.Client) SqsActions {
 	return SqsActions{
 		SqsClient: sqsCliet,
 	}
 }
 
-func (actor SqsActions) CreateSqsQueue(ctx context.Context, queueName string, isFifo bool) string {
+func (actor SqsActions) CreateSqsQueue(ctx context.Context, queueName string, isFifo bool) (string, error) {
 	queueAttributes := map[string]string{}
 
 	if isFifo {
@@ -36,75 +38,167 @@ func (actor SqsActions) CreateSqsQueue(ctx context.Context, queueName string, is
 		Attributes: queueAttributes,
 	})
 	if err != nil {
-		helpers.LogError(logFile, err.Error())
-		os.Exit(1)
+		return "", classify("create queue "+queueName, err)
 	}
 
-	return *queue.QueueUrl
+	return *queue.QueueUrl, nil
 }
 
-func (actor SqsActions) GetQueue(ctx context.Context, queueName string) string {
+// GetQueue returns ErrNotFound when the queue does not exist, any other
+// error means the lookup itself failed.
+func (actor SqsActions) GetQueue(ctx context.Context, queueName string) (string, error) {
 	queue, err := actor.SqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
-		QueueName: aws.String(queueName),
+		QueueName:              aws.String(queueName),
 		QueueOwnerAWSAccountId: nil,
 	})
 	if err != nil {
-		return ""
+		return "", classify("get queue "+queueName, err)
+	}
+
+	return *queue.QueueUrl, nil
+}
+
+func (actor SqsActions) GetOrCreateQueue(ctx context.Context, queueName string, isFifo bool) (string, error) {
+	queueUrl, err := actor.GetQueue(ctx, queueName)
+	if errors.Is(err, ErrNotFound) {
+		return actor.CreateSqsQueue(ctx, queueName, isFifo)
 	}
 
-	return *queue.QueueUrl
+	return queueUrl, err
 }
 
-func (actor SqsActions) SendMessage(ctx context.Context, queueUrl string, message []byte, group *string, dupId *string) {
+func (actor SqsActions) SendMessage(ctx context.Context, queueUrl string, message []byte, attributes map[string]types.MessageAttributeValue, group *string, dupId *string) error {
+	name := metrics.QueueName(queueUrl)
+	start := time.Now()
 	res, err := actor.SqsClient.SendMessage(ctx, &sqs.SendMessageInput{
 		MessageBody:             aws.String(string(message)),
 		QueueUrl:                &queueUrl,
 		DelaySeconds:            0,
-		MessageAttributes:       nil,
+		MessageAttributes:       attributes,
 		MessageDeduplicationId:  dupId,
 		MessageGroupId:          group,
 		MessageSystemAttributes: nil,
 	})
+	metrics.SendDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
 	if err != nil {
-		helpers.LogError(logFile, err.Error())
-		os.Exit(1)
+		metrics.MessagesFailed.WithLabelValues(name, metrics.StageSend).Inc()
+		return classify("send message", err)
 	}
+	metrics.MessagesSent.WithLabelValues(name).Inc()
 
-	log.Printf("the message with id %v is sent\n", *res.MessageId)
+	logger.DebugContext(ctx, "message sent", "queue", name, "message_id", *res.MessageId)
+	return nil
 }
 
-func (actor SqsActions) GetMessages(ctx context.Context, queueUrl string) []types.Message {
+// GetMessages long-polls for up to 20 seconds. A poll cancelled through ctx
+// returns no messages and no error, that is how consumers stop.
+func (actor SqsActions) GetMessages(ctx context.Context, queueUrl string) ([]types.Message, error) {
+	name := metrics.QueueName(queueUrl)
+	start := time.Now()
 	res, err := actor.SqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
-		QueueUrl: aws.String(queueUrl),
-		MaxNumberOfMessages: 8,
-		WaitTimeSeconds: 20,
+		QueueUrl:              aws.String(queueUrl),
+		MaxNumberOfMessages:   8,
+		WaitTimeSeconds:       20,
+		MessageAttributeNames: []string{"All"},
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{
+			types.MessageSystemAttributeNameMessageGroupId,
+			types.MessageSystemAttributeNameMessageDeduplicationId,
+			types.MessageSystemAttributeNameApproximateReceiveCount,
+		},
 	})
 	if err != nil {
-		helpers.LogError(logFile, err.Error())
-		os.Exit(1)
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+		metrics.MessagesFailed.WithLabelValues(name, metrics.StageReceive).Inc()
+		return nil, classify("receive messages", err)
 	}
-	
-	return res.Messages
+	metrics.ReceiveDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	metrics.MessagesReceived.WithLabelValues(name).Add(float64(len(res.Messages)))
+
+	return res.Messages, nil
 }
 
-func (actor SqsActions) PurgeQueue(ctx context.Context, queueUrl string) {
+func (actor SqsActions) PurgeQueue(ctx context.Context, queueUrl string) error {
 	_, err := actor.SqsClient.PurgeQueue(ctx, &sqs.PurgeQueueInput{
 		QueueUrl: &queueUrl,
 	})
+
+	return classify("purge queue", err)
+}
+
+func (actor SqsActions) DeleteMessage(ctx context.Context, queueUrl string, handle string) error {
+	_, err := actor.SqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      &queueUrl,
+		ReceiptHandle: &handle,
+	})
 	if err != nil {
-		helpers.LogError(logFile, err.Error())
-		os.Exit(1)
+		metrics.MessagesFailed.WithLabelValues(metrics.QueueName(queueUrl), metrics.StageDelete).Inc()
+		return classify("delete message", err)
 	}
+	metrics.MessagesDeleted.WithLabelValues(metrics.QueueName(queueUrl)).Inc()
+
+	return nil
 }
 
-func (actor SqsActions) DeleteMessage(ctx context.Context, queueUrl string, handle string) {
-	_, err := actor.SqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+// ChangeMessageVisibility hides an in-flight message for another timeout,
+// counted from now.
+func (actor SqsActions) ChangeMessageVisibility(ctx context.Context, queueUrl string, handle string, timeout time.Duration) error {
+	_, err := actor.SqsClient.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          &queueUrl,
+		ReceiptHandle:     &handle,
+		VisibilityTimeout: int32(timeout / time.Second),
+	})
+
+	return classify("change message visibility", err)
+}
+
+// Stats reads the approximate message counts SQS keeps for queueUrl, they
+// lag behind sends and deletes by up to a minute.
+func (actor SqsActions) Stats(ctx context.Context, queueUrl string) (Stats, error) {
+	res, err := actor.SqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
 		QueueUrl: &queueUrl,
-		ReceiptHandle: &handle,
+		AttributeNames: []types.QueueAttributeName{
+			types.QueueAttributeNameApproximateNumberOfMessages,
+			types.QueueAttributeNameApproximateNumberOfMessagesNotVisible,
+		},
 	})
 	if err != nil {
-		helpers.LogError(logFile, err.Error())
-		os.Exit(1)
+		return Stats{}, classify("get queue attributes", err)
 	}
+
+	var stats Stats
+	stats.Visible, _ = strconv.Atoi(res.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)])
+	stats.InFlight, _ = strconv.Atoi(res.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessagesNotVisible)])
+
+	return stats, nil
 }
 
+// AttachDeadLetterQueue sets a RedrivePolicy on queueUrl so messages received
+// more than maxReceiveCount times are moved to dlqUrl.
+func (actor SqsActions) AttachDeadLetterQueue(ctx context.Context, queueUrl string, dlqUrl string, maxReceiveCount int) error {
+	attributes, err := actor.SqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       &dlqUrl,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return classify("get dead-letter queue arn", err)
+	}
+
+	policy, err := json.Marshal(map[string]string{
+		"deadLetterTargetArn": attributes.Attributes[string(types.QueueAttributeNameQueueArn)],
+		"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = actor.SqsClient.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl: &queueUrl,
+		Attributes: map[string]string{
+			string(types.QueueAttributeNameRedrivePolicy): string(policy),
+		},
+	})
+
+	return classify("set redrive policy", err)
+}
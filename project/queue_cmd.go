@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"test/starkbank/project/queue"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// purgeCmd deletes every message in the queue, or in its dead-letter queue:
+//
+//	project queue purge -yes [-dlq]
+func purgeCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	yes := flags.Bool("yes", false, "confirm that every message is to be deleted")
+	dlq := flags.Bool("dlq", false, "purge the dead-letter queue instead")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if !*yes {
+		return usagef("purge deletes every message for good, pass -yes to confirm")
+	}
+
+	if err := e.setupQueue(ctx); err != nil {
+		return err
+	}
+	name, url := e.pick(*dlq)
+
+	if err := e.queue.PurgeQueue(ctx, url); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s purged.\n", name)
+	return nil
+}
+
+// statsCmd counts the messages in the queue and its dead-letter queue:
+//
+//	project queue stats [-json]
+func statsCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	asJson := flags.Bool("json", false, "print the counts as JSON instead of a table")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if err := e.setupQueue(ctx); err != nil {
+		return err
+	}
+
+	type row struct {
+		Queue    string `json:"queue"`
+		Visible  int    `json:"visible"`
+		InFlight int    `json:"in_flight"`
+	}
+	var rows []row
+	for _, dlq := range []bool{false, true} {
+		name, url := e.pick(dlq)
+		stats, err := e.queue.Stats(ctx, url)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row{Queue: name, Visible: stats.Visible, InFlight: stats.InFlight})
+	}
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUEUE\tVISIBLE\tIN FLIGHT")
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", r.Queue, r.Visible, r.InFlight)
+	}
+	return w.Flush()
+}
+
+// peekCmd receives messages and makes them visible again right away. Every
+// peek is a receive, so it counts towards QUEUE_MAX_RECEIVE_COUNT:
+//
+//	project queue peek [-n 10] [-dlq] [-wait 5s] [-json]
+func peekCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	count := flags.Int("n", 10, "show at most this many messages")
+	dlq := flags.Bool("dlq", false, "peek at the dead-letter queue instead")
+	wait := flags.Duration("wait", 5*time.Second, "how long to wait for messages")
+	asJson := flags.Bool("json", false, "print JSON lines instead of a table")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *count < 1 {
+		return usagef("-n must be at least 1")
+	}
+
+	if err := e.setupQueue(ctx); err != nil {
+		return err
+	}
+	_, url := e.pick(*dlq)
+
+	var peeked []types.Message
+	defer func() {
+		// put back everything received, even past count or on error
+		release := context.WithoutCancel(ctx)
+		for _, message := range peeked {
+			if err := e.queue.ChangeMessageVisibility(release, url, *message.ReceiptHandle, 0); err != nil {
+				logger.Warn("peeked message stays hidden until its visibility timeout", "message_id", *message.MessageId, "err", err)
+			}
+		}
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, *wait)
+	defer cancel()
+	for len(peeked) < *count {
+		messages, err := e.queue.GetMessages(waitCtx, url)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			break
+		}
+		peeked = append(peeked, messages...)
+	}
+	shown := peeked[:min(len(peeked), *count)]
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, message := range shown {
+			if err := encoder.Encode(peekRow(message)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MESSAGE ID\tGROUP\tRECEIVES\tTYPE\tBODY")
+	for _, message := range shown {
+		r := peekRow(message)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.MessageId, r.Group, r.ReceiveCount, r.Type, r.Body)
+	}
+	fmt.Fprintf(w, "%d messages\n", len(shown))
+	return w.Flush()
+}
+
+type peeked struct {
+	MessageId     string          `json:"message_id"`
+	Group         string          `json:"group,omitempty"`
+	ReceiveCount  string          `json:"receive_count"`
+	Type          string          `json:"type,omitempty"`
+	Version       int             `json:"version,omitempty"`
+	CorrelationId string          `json:"correlation_id,omitempty"`
+	Body          json.RawMessage `json:"body"`
+}
+
+func peekRow(message types.Message) peeked {
+	row := peeked{
+		MessageId:    *message.MessageId,
+		Group:        message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)],
+		ReceiveCount: message.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)],
+	}
+	if envelope, err := queue.OpenEnvelope(message); err == nil {
+		row.Type, row.Version, row.CorrelationId = envelope.Type, envelope.Version, envelope.CorrelationId
+		row.Body = envelope.Body
+	}
+	if !json.Valid(row.Body) {
+		row.Body, _ = json.Marshal(*message.Body)
+	}
+
+	return row
+}
+
+// redriveCmd moves every dead letter back to the queue:
+//
+//	project dlq redrive
+func redriveCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if err := e.setupQueue(ctx); err != nil {
+		return err
+	}
+
+	moved, err := queue.Redrive(ctx, e.queue, e.dlqUrl, e.queueUrl)
+	fmt.Printf("%d messages moved from %s back to %s\n", moved, queue.DeadLetterName(e.cfg.Queue.Name), e.cfg.Queue.Name)
+	return err
+}
+
+// pick returns the name and url of the queue, or of its dead-letter queue.
+func (e *env) pick(dlq bool) (string, string) {
+	if dlq {
+		return queue.DeadLetterName(e.cfg.Queue.Name), e.dlqUrl
+	}
+
+	return e.cfg.Queue.Name, e.queueUrl
+}
@@ -0,0 +1,207 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"test/starkbank/mocked/app/model"
+	"test/starkbank/project/ledger"
+	"test/starkbank/project/queue"
+	"test/starkbank/project/requests"
+	"time"
+)
+
+// Kinds of discrepancy found between the ledger and the API.
+const (
+	Missing        = "missing"
+	Duplicated     = "duplicated"
+	AmountMismatch = "amount_mismatch"
+	StatusDrift    = "status_drift"
+)
+
+type (
+	// InvoiceGetter is the part of requests.InvoiceClient reconciliation
+	// needs.
+	InvoiceGetter interface {
+		Get(ctx context.Context, id int64) (model.InviceResp, error)
+	}
+
+	Issue struct {
+		Kind           string `json:"kind"`
+		InvoiceId      int64  `json:"invoice_id"`
+		IdempotencyKey string `json:"idempotency_key,omitempty"`
+		// Ledger and Api hold the values that disagree, for duplicates the
+		// other invoice ids created for the same key.
+		Ledger string `json:"ledger"`
+		Api    string `json:"api"`
+	}
+
+	Report struct {
+		Checked int     `json:"checked"`
+		Matched int     `json:"matched"`
+		Issues  []Issue `json:"issues"`
+	}
+)
+
+// Run compares every invoice in the ledger with what the API returns for it.
+// Invoices already requeued are skipped, their replacement is checked
+// instead. Duplicates are only reported among invoices the API has. An API
+// error other than not found stops the run.
+func Run(ctx context.Context, book *ledger.Ledger, api InvoiceGetter) (Report, error) {
+	entries, err := book.Query(ledger.Filter{})
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{Issues: []Issue{}}
+	byKey := map[string][]ledger.Entry{}
+	for _, entry := range entries {
+		if !entry.RequeuedAt.IsZero() {
+			continue
+		}
+
+		report.Checked++
+		found, err := api.Get(ctx, entry.InvoiceId)
+		if errors.Is(err, requests.ErrInvoiceNotFound) {
+			report.Issues = append(report.Issues, Issue{
+				Kind:           Missing,
+				InvoiceId:      entry.InvoiceId,
+				IdempotencyKey: entry.IdempotencyKey,
+				Ledger:         fmt.Sprintf("%s %s", entry.Amount, entry.Status),
+				Api:            "not found",
+			})
+			continue
+		}
+		if err != nil {
+			return report, fmt.Errorf("error getting invoice %d: %w", entry.InvoiceId, err)
+		}
+		if entry.IdempotencyKey != "" {
+			byKey[entry.IdempotencyKey] = append(byKey[entry.IdempotencyKey], entry)
+		}
+
+		matched := true
+		if found.Amount != entry.Amount {
+			matched = false
+			report.Issues = append(report.Issues, Issue{
+				Kind:           AmountMismatch,
+				InvoiceId:      entry.InvoiceId,
+				IdempotencyKey: entry.IdempotencyKey,
+				Ledger:         entry.Amount.String(),
+				Api:            found.Amount.String(),
+			})
+		}
+		if found.Status != entry.Status {
+			matched = false
+			report.Issues = append(report.Issues, Issue{
+				Kind:           StatusDrift,
+				InvoiceId:      entry.InvoiceId,
+				IdempotencyKey: entry.IdempotencyKey,
+				Ledger:         entry.Status,
+				Api:            found.Status,
+			})
+		}
+		if matched {
+			report.Matched++
+		}
+	}
+
+	report.Issues = append(report.Issues, duplicates(byKey)...)
+	return report, nil
+}
+
+// duplicates reports keys that ended up with more than one invoice on the
+// API, which means the same message was created twice. byKey only holds
+// invoices the API returned, one it doesn't have is missing rather than a
+// duplicate.
+func duplicates(byKey map[string][]ledger.Entry) []Issue {
+	var issues []Issue
+	for key, entries := range byKey {
+		if len(entries) < 2 {
+			continue
+		}
+
+		ids := make([]string, len(entries))
+		for i, entry := range entries {
+			ids[i] = fmt.Sprint(entry.InvoiceId)
+		}
+		for _, entry := range entries[1:] {
+			issues = append(issues, Issue{
+				Kind:           Duplicated,
+				InvoiceId:      entry.InvoiceId,
+				IdempotencyKey: key,
+				Ledger:         "1 invoice",
+				Api:            "invoices " + strings.Join(ids, ", "),
+			})
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].InvoiceId < issues[j].InvoiceId })
+
+	return issues
+}
+
+// Requeue queues the missing invoices of report again and marks their
+// ledger entries as requeued. They are queued under reconcile-<invoice id>
+// rather than their original idempotency key, which would have the API
+// replay the invoice that went missing. The key is derived from the missing
+// invoice, so a Requeue repeated before the entries were marked is
+// deduplicated instead of creating the invoice twice. It returns how many
+// were queued.
+func Requeue(ctx context.Context, report Report, book *ledger.Ledger, q queue.Queue, queueUrl string) (int, error) {
+	var missing []ledger.Entry
+	var messages []queue.BatchMessage
+	for _, issue := range report.Issues {
+		if issue.Kind != Missing {
+			continue
+		}
+		entry, ok, err := book.Get(issue.InvoiceId)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+
+		invoice := requests.Invoice{Amount: entry.Amount, Name: entry.Name, TaxId: entry.TaxId}
+		message, err := requests.InvoiceMessage(invoice, "reconcile", RequeueKey(entry.InvoiceId))
+		if err != nil {
+			return 0, err
+		}
+		missing = append(missing, entry)
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	result, err := q.SendMessageBatch(ctx, queueUrl, messages)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := map[string]bool{}
+	for _, id := range result.Successful {
+		sent[id] = true
+	}
+	now := time.Now().UTC()
+	requeued := 0
+	for i, entry := range missing {
+		if !sent[messages[i].Id] {
+			continue
+		}
+		if _, err := book.Update(entry.InvoiceId, func(entry *ledger.Entry) {
+			entry.RequeuedAt = now
+		}); err != nil {
+			return requeued, err
+		}
+		requeued++
+	}
+
+	return requeued, result.Err()
+}
+
+// RequeueKey is the idempotency key a missing invoice is queued again with.
+func RequeueKey(invoiceId int64) string {
+	return fmt.Sprintf("reconcile-%d", invoiceId)
+}
@@ -0,0 +1,173 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"test/starkbank/mocked/app/model"
+	"test/starkbank/project/ledger"
+	"test/starkbank/project/queue"
+	"test/starkbank/project/requests"
+	"testing"
+	"time"
+)
+
+// fakeApi has the invoices in it, any other id is not found.
+type fakeApi map[int64]model.InviceResp
+
+func (f fakeApi) Get(ctx context.Context, id int64) (model.InviceResp, error) {
+	invoice, ok := f[id]
+	if !ok {
+		return model.InviceResp{}, fmt.Errorf("%w: %d", requests.ErrInvoiceNotFound, id)
+	}
+
+	return invoice, nil
+}
+
+func newBook(t *testing.T, entries ...ledger.Entry) *ledger.Ledger {
+	t.Helper()
+
+	book, err := ledger.Open(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if err := book.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return book
+}
+
+func entry(id int64, key string) ledger.Entry {
+	return ledger.Entry{InvoiceId: id, IdempotencyKey: key, Name: "Jasnah", TaxId: "15555555512", Amount: 10000, Fee: 340, Status: "PAYED"}
+}
+
+func onApi(id int64) model.InviceResp {
+	return model.InviceResp{ID: id, Amount: 10000, Fee: 340, Status: "PAYED"}
+}
+
+func TestRun(t *testing.T) {
+	requeued := entry(9, "key-9")
+	requeued.RequeuedAt = time.Now()
+
+	tests := []struct {
+		name        string
+		entries     []ledger.Entry
+		api         fakeApi
+		wantChecked int
+		wantMatched int
+		// wantIssues are kind:invoice id
+		wantIssues []string
+	}{
+		{
+			name:        "matching",
+			entries:     []ledger.Entry{entry(1, "key-1"), entry(2, "key-2")},
+			api:         fakeApi{1: onApi(1), 2: onApi(2)},
+			wantChecked: 2,
+			wantMatched: 2,
+		},
+		{
+			name:        "missing",
+			entries:     []ledger.Entry{entry(1, "key-1"), entry(2, "key-2")},
+			api:         fakeApi{1: onApi(1)},
+			wantChecked: 2,
+			wantMatched: 1,
+			wantIssues:  []string{"missing:2"},
+		},
+		{
+			name:        "duplicated",
+			entries:     []ledger.Entry{entry(1, "key-1"), entry(2, "key-1")},
+			api:         fakeApi{1: onApi(1), 2: onApi(2)},
+			wantChecked: 2,
+			wantMatched: 2,
+			wantIssues:  []string{"duplicated:2"},
+		},
+		{
+			name:        "a duplicate the API doesn't have is missing",
+			entries:     []ledger.Entry{entry(1, "key-1"), entry(2, "key-1")},
+			api:         fakeApi{1: onApi(1)},
+			wantChecked: 2,
+			wantMatched: 1,
+			wantIssues:  []string{"missing:2"},
+		},
+		{
+			name:    "amount and status drift",
+			entries: []ledger.Entry{entry(1, "key-1")},
+			api: fakeApi{1: {
+				ID: 1, Amount: 9999, Fee: 340, Status: "CREATED",
+			}},
+			wantChecked: 1,
+			wantIssues:  []string{"amount_mismatch:1", "status_drift:1"},
+		},
+		{
+			name:        "requeued entries are skipped",
+			entries:     []ledger.Entry{entry(1, "key-1"), requeued},
+			api:         fakeApi{1: onApi(1)},
+			wantChecked: 1,
+			wantMatched: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Run(context.Background(), newBook(t, tt.entries...), tt.api)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var issues []string
+			for _, issue := range report.Issues {
+				issues = append(issues, fmt.Sprintf("%s:%d", issue.Kind, issue.InvoiceId))
+			}
+			if report.Checked != tt.wantChecked || report.Matched != tt.wantMatched || !slices.Equal(issues, tt.wantIssues) {
+				t.Fatalf("Run() = %d checked, %d matched, issues %v, want %d, %d, %v", report.Checked, report.Matched, issues, tt.wantChecked, tt.wantMatched, tt.wantIssues)
+			}
+		})
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	book := newBook(t, entry(1, "key-1"), entry(2, "key-2"))
+	report, err := Run(context.Background(), book, fakeApi{1: onApi(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := queue.NewMemoryQueue()
+	url, _ := q.CreateSqsQueue(context.Background(), "invoices.fifo", true)
+
+	// the second call stands in for a Requeue repeated before the ledger
+	// was updated, its message is dropped as a duplicate
+	for range 2 {
+		requeued, err := Requeue(context.Background(), report, book, q, url)
+		if err != nil || requeued != 1 {
+			t.Fatalf("Requeue() = %d, %v, want 1", requeued, err)
+		}
+	}
+
+	messages, err := q.Receive(context.Background(), url, 10, 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("queued %d messages, want 1", len(messages))
+	}
+	envelope, err := queue.OpenEnvelope(messages[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.IdempotencyKey != RequeueKey(2) {
+		t.Fatalf("requeued with key %q, want %q", envelope.IdempotencyKey, RequeueKey(2))
+	}
+
+	missing, _, _ := book.Get(2)
+	if missing.RequeuedAt.IsZero() {
+		t.Fatal("the missing invoice was not marked as requeued")
+	}
+	if kept, _, _ := book.Get(1); !kept.RequeuedAt.IsZero() {
+		t.Fatal("an invoice the API has was marked as requeued")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"test/starkbank/project/guard"
+	"test/starkbank/project/reconcile"
+	"test/starkbank/project/requests"
+	"text/tabwriter"
+)
+
+// reconcileCmd checks the ledger against the API:
+//
+//	project reconcile [-json] [-requeue]
+func reconcileCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	asJson := flags.Bool("json", false, "print the report as JSON instead of a table")
+	requeue := flags.Bool("requeue", false, "queue the invoices missing on the API again")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	book, err := e.openLedger()
+	if err != nil {
+		return err
+	}
+
+	apiGuard, err := guard.New("invoice_api", e.cfg.ApiGuard)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errConfig, err)
+	}
+
+	report, err := reconcile.Run(ctx, book, requests.NewInvoiceClient(e.cfg.Api.Url, apiGuard))
+	if err != nil {
+		return err
+	}
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KIND\tINVOICE\tKEY\tLEDGER\tAPI")
+		for _, issue := range report.Issues {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", issue.Kind, issue.InvoiceId, issue.IdempotencyKey, issue.Ledger, issue.Api)
+		}
+		fmt.Fprintf(w, "%d checked, %d matched, %d issues\n", report.Checked, report.Matched, len(report.Issues))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if *requeue {
+		if err := e.setupQueue(ctx); err != nil {
+			return err
+		}
+		requeued, err := reconcile.Requeue(ctx, report, book, e.queue, e.queueUrl)
+		fmt.Fprintf(os.Stderr, "%d missing invoices queued again\n", requeued)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
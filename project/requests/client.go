@@ -0,0 +1,177 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"test/starkbank/mocked/app/model"
+	"test/starkbank/project/guard"
+	"test/starkbank/project/metrics"
+	"test/starkbank/project/queue"
+	"time"
+)
+
+// ErrInvoiceNotFound is returned by Get when the API has no invoice with
+// the id.
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// InvoiceClient calls the invoice API. Calls go through Guard, which rate
+// limits them and fails them with guard.ErrOpen while the API keeps
+// answering 5xx or timing out; a nil Guard lets every call through.
+type InvoiceClient struct {
+	BaseUrl    string
+	HttpClient *http.Client
+	Guard      *guard.Guard
+}
+
+func NewInvoiceClient(baseUrl string, g *guard.Guard) InvoiceClient {
+	return InvoiceClient{
+		BaseUrl:    strings.TrimRight(baseUrl, "/"),
+		HttpClient: &http.Client{Timeout: 15 * time.Second},
+		Guard:      g,
+	}
+}
+
+// Submit posts the invoice to the mocked API and only reports success when
+// the server answers 201, so the caller knows when it is safe to ack the message.
+// Submitting again with the same idempotencyKey returns the invoice created
+// the first time instead of a new one.
+func (c InvoiceClient) Submit(ctx context.Context, invoice Invoice, idempotencyKey string) (queue.CreatedInvoice, error) {
+	body, err := json.Marshal(invoice)
+	if err != nil {
+		return queue.CreatedInvoice{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseUrl+"/invoice", bytes.NewReader(body))
+	if err != nil {
+		return queue.CreatedInvoice{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	res, err := c.do(req, "create_invoice")
+	if err != nil {
+		return queue.CreatedInvoice{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return queue.CreatedInvoice{}, fmt.Errorf("invoice api returned %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var resp model.InviceResp
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return queue.CreatedInvoice{}, fmt.Errorf("error decoding invoice response: %w", err)
+	}
+
+	return queue.CreatedInvoice{
+		Id:        resp.ID,
+		Name:      invoice.Name,
+		TaxId:     resp.TaxId,
+		Amount:    resp.Amount,
+		Fee:       resp.Fee,
+		Status:    resp.Status,
+		CreatedAt: resp.CreatedAt,
+	}, nil
+}
+
+// Get fetches an invoice as the API has it now.
+func (c InvoiceClient) Get(ctx context.Context, id int64) (model.InviceResp, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseUrl+"/invoice/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return model.InviceResp{}, err
+	}
+
+	res, err := c.do(req, "get_invoice")
+	if err != nil {
+		return model.InviceResp{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return model.InviceResp{}, fmt.Errorf("%w: %d", ErrInvoiceNotFound, id)
+	}
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return model.InviceResp{}, fmt.Errorf("invoice api returned %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var resp model.InviceResp
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return model.InviceResp{}, fmt.Errorf("error decoding invoice response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// Transfer posts a transfer to the mocked API. Like Submit, a repeated
+// idempotencyKey returns the first transfer instead of paying twice.
+func (c InvoiceClient) Transfer(ctx context.Context, transfer model.TransferRequest, idempotencyKey string) (model.TransferResp, error) {
+	body, err := json.Marshal(transfer)
+	if err != nil {
+		return model.TransferResp{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseUrl+"/transfer", bytes.NewReader(body))
+	if err != nil {
+		return model.TransferResp{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+
+	res, err := c.do(req, "create_transfer")
+	if err != nil {
+		return model.TransferResp{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return model.TransferResp{}, fmt.Errorf("transfer api returned %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var resp model.TransferResp
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return model.TransferResp{}, fmt.Errorf("error decoding transfer response: %w", err)
+	}
+
+	return resp, nil
+}
+
+// do sends req once the guard allows it and records its latency under
+// endpoint. Server errors and timeouts count against the breaker, a request
+// given up by its caller does not.
+func (c InvoiceClient) do(req *http.Request, endpoint string) (*http.Response, error) {
+	done, err := c.Guard.Allow(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", endpoint, err)
+	}
+
+	start := time.Now()
+	res, err := c.HttpClient.Do(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		done(guard.Abandoned)
+	case err != nil || res.StatusCode >= 500:
+		done(guard.Failure)
+	default:
+		done(guard.Success)
+	}
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+	}
+	metrics.ApiDuration.WithLabelValues(endpoint, code).Observe(time.Since(start).Seconds())
+
+	return res, err
+}
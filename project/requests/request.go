@@ -2,106 +2,317 @@ package requests
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"sync"
+	"test/starkbank/broker"
+	"test/starkbank/broker/fifo"
+	"test/starkbank/generator"
 	"test/starkbank/helpers"
+	"test/starkbank/logging"
+	"test/starkbank/money"
+	"test/starkbank/project/consumer"
+	"test/starkbank/project/guard"
+	"test/starkbank/project/ledger"
+	"test/starkbank/project/metrics"
 	"test/starkbank/project/queue"
+	"test/starkbank/project/scheduler"
+	"test/starkbank/project/settings"
 	"time"
 
 	"github.com/gosimple/slug"
 )
 
-var logFile = "../logs/create_invoice.txt"
+var logger = logging.For("requests")
+
+// invoiceVersion is the schema version of Invoice as it is queued. Bump it
+// when the body changes in a way older consumers can't read.
+const invoiceVersion = 1
 
 type (
 	Invoice struct {
-		Amount float64 `json:"amount" xml:"amount" form:"amount" query:"amount"`
-		Name   string  `json:"name" xml:"name" form:"name" query:"name"`
-		TaxId  string  `json:"tax_id" xml:"tax_id" form:"tax_id" query:"tax_id"`
+		Amount money.Money `json:"amount" xml:"amount" form:"amount" query:"amount"`
+		Name   string      `json:"name" xml:"name" form:"name" query:"name"`
+		TaxId  string      `json:"tax_id" xml:"tax_id" form:"tax_id" query:"tax_id"`
 	}
 )
 
-func CreateInvoice(queueUrl string, sqsClient queue.SqsActions) {
-	// For the example, we use 3 seconds. For your use case, change this to 3 * time.Hour.
-	d := 3 * time.Minute
-
-	ticker := time.NewTicker(d)
-	defer ticker.Stop()
-
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// Create a context that will be canceled after 24 minutes.
-	ctx, cancel := context.WithTimeout(context.Background(), 24*time.Minute)
+// CreateInvoice produces invoices on schedule and consumes them until the
+// schedule is over or ctx is cancelled. On cancellation the invoices already
+// generated and the handlers already running get conf.Consumer.DrainTimeout
+// to finish, then a summary of what was left behind is printed. The error
+// is the one that stopped the consumer, if any.
+func CreateInvoice(parent context.Context, conf settings.Config, queueUrl string, sqsClient queue.Queue, schedule *scheduler.Scheduler, book *ledger.Ledger) error {
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
+	cfg := schedule.Config()
 
-	i := 0
+	gen := generator.FromConfig(conf.Generator)
+	logger.Info("generating invoices", "seed", gen.Seed)
 
+	invoices, err := NewConsumer(conf, queueUrl, sqsClient, book)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var consumeErr error
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for {
-			select {
-			case <-ctx.Done():
-				fmt.Println("Stopping the periodic task due to timeout.")
-				return
-			case t := <-ticker.C:
-				mu.Lock()
-				i++
-				queueInvoices(ctx, i, sqsClient, queueUrl)
-				fmt.Printf("Sleeping for 30s at %v \n", t.Format("15:04:05"))
-				time.Sleep(30*time.Second)
-				messages := sqsClient.GetMessages(ctx, queueUrl)
-				var invoice Invoice
-				for _, message := range messages {
-					err := json.Unmarshal([]byte(*message.Body), &invoice)
-					if err != nil {
-						helpers.LogError(logFile, err.Error())
-						continue
-					}
-
-					requestCreation(invoice)
-					sqsClient.DeleteMessage(ctx, queueUrl, *message.ReceiptHandle)
-				}
-				mu.Unlock()
-				fmt.Printf("Tick at %v, running the task... \n", t.Format("15:04:05"))
-			}
-		}
+		consumeErr = invoices.Run(ctx)
+	}()
+
+	logger.Info("periodic task scheduled", "spec", cfg.Spec, "batch_size", cfg.BatchSize)
+
+	// runs only generate invoices into outbox, sendOutbox takes them out in
+	// batches as soon as they are there. A full outbox holds the schedule
+	// back until the broker catches up.
+	outbox := fifo.New[Invoice](2 * cfg.BatchSize)
+	sending, stopSending := context.WithCancel(ctx)
+	defer stopSending()
+	sent := 0
+	sender := make(chan struct{})
+	go func() {
+		defer close(sender)
+		sent = sendOutbox(sending, ctx, conf.Consumer.DrainTimeout, outbox, sqsClient, queueUrl)
 	}()
 
-	fmt.Printf("Periodic task scheduled every %m. Will stop after 24 minutes.\n", d)
+	schedule.Run(ctx, func(ctx context.Context, run scheduler.Run) {
+		generated := generateInvoices(ctx, gen, run.BatchSize, outbox)
+		logger.Info("invoices generated", "run", run.Number, "generated", generated, "size", run.BatchSize)
+	})
 
-	// Wait for the context to be canceled, which happens after 24 minutes.
-	<-ctx.Done()
-	// Wait for the goroutine to finish.
+	// the sender empties the outbox before it stops
+	stopSending()
+	<-sender
+	logger.Info("schedule over, invoices queued", "sent", sent)
+
+	// stop polling once the schedule is over
+	cancel()
 	wg.Wait()
-	fmt.Println("Invoices queued.")
 
-	fmt.Println("Invoices Requested")
+	logger.Info("consumer stopped")
+
+	stats := invoices.Stats()
+	fmt.Printf("Summary: %d sent, %d received, %d processed, %d failed, %d left unacked.\n", sent, stats.Received, stats.Processed, stats.Failed, stats.Unacked)
+	return consumeErr
+}
+
+// NewConsumer returns a consumer for every message type the project
+// queues. Every created invoice is recorded in book, and transferred out
+// once it is PAYED when conf has a destination account.
+func NewConsumer(conf settings.Config, queueUrl string, sqsClient queue.Queue, book *ledger.Ledger) (*consumer.Consumer, error) {
+	apiGuard, err := guard.New("invoice_api", conf.ApiGuard)
+	if err != nil {
+		return nil, err
+	}
+	client := NewInvoiceClient(conf.Api.Url, apiGuard)
+
+	router := consumer.NewRouter()
+	var paid func(ctx context.Context, invoice PaidInvoice) error
+	if dest := conf.Transfer; dest.Enabled() {
+		router.Handle(queue.TypeTransferCreate, transferVersion, transferHandler(client, book, dest))
+		paid = func(ctx context.Context, invoice PaidInvoice) error {
+			message, err := PaidMessage(invoice)
+			if err != nil {
+				return err
+			}
+			return sqsClient.SendMessage(ctx, queueUrl, message.Body, message.Attributes, message.Group, message.DupId)
+		}
+		logger.Info("paid invoices are transferred", "account", dest.Account)
+	} else {
+		logger.Info("transfers are off, TRANSFER_ACCOUNT is not set")
+	}
+	router.Handle(queue.TypeInvoiceCreate, invoiceVersion, invoiceHandler(client, book, paid))
+
+	return consumer.New(conf.Consumer, sqsClient, queueUrl, router.Handler())
+}
+
+// invoiceHandler decodes a queued invoice, submits it and records the result.
+// Errors leave the message on the queue so SQS redelivers it; a redelivered
+// invoice that was already created is replayed by the API and recorded again,
+// keeping the transfer already made for it. Invoices are passed to paid,
+// which waits for them to be PAYED, unless paid is nil or they were
+// transferred already.
+func invoiceHandler(client InvoiceClient, book *ledger.Ledger, paid func(ctx context.Context, invoice PaidInvoice) error) consumer.EnvelopeHandler {
+	return func(ctx context.Context, envelope queue.Envelope) error {
+		var invoice Invoice
+		if err := json.Unmarshal(envelope.Body, &invoice); err != nil {
+			return err
+		}
+
+		submittedAt := time.Now().UTC()
+		created, err := requestCreation(ctx, client, invoice, envelope.IdempotencyKey)
+		if err != nil {
+			return err
+		}
+
+		// only the invoice fields are set, a transfer recorded meanwhile by
+		// the paid event of an earlier delivery is kept
+		entry, err := book.Update(created.Id, func(entry *ledger.Entry) {
+			entry.MessageId = envelope.MessageId
+			entry.IdempotencyKey = envelope.IdempotencyKey
+			entry.CorrelationId = envelope.CorrelationId
+			entry.Name = created.Name
+			entry.TaxId = created.TaxId
+			entry.Amount = created.Amount
+			entry.Fee = created.Fee
+			entry.Status = created.Status
+			entry.SubmittedAt = submittedAt
+			entry.CreatedAt = created.CreatedAt
+		})
+		if err != nil {
+			return err
+		}
+
+		if paid == nil || entry.Transferred() {
+			return nil
+		}
+		return paid(ctx, PaidInvoice{InvoiceId: entry.InvoiceId, Amount: entry.Amount, Fee: entry.Fee})
+	}
+}
+
+// generateInvoices puts n generated invoices in outbox, waiting for room
+// when it is full, and returns how many it put before ctx was done.
+func generateInvoices(ctx context.Context, gen *generator.Generator, n int, outbox *fifo.Queue[Invoice]) int {
+	for i := range n {
+		invoice := Invoice{Amount: gen.Amount(), Name: gen.Name(), TaxId: gen.TaxId()}
+		if err := outbox.Enqueue(ctx, invoice); err != nil {
+			return i
+		}
+	}
+
+	return n
 }
 
-func queueInvoices(ctx context.Context, requestId int, sqsClient queue.SqsActions, queueUrl string) {
-	for y := 1; y <= 8; y++ {
-		newInvoice := Invoice{
-			Amount: 4000.10 + float64(requestId),
-			Name:   "Kaladin Stormblessed",
-			TaxId:  "11111111111",
+// sendOutbox queues the invoices of outbox in batches of up to 10, each in
+// its own message group, until ctx is done. What is left in outbox is then
+// sent as well. Sends get drainTimeout to finish once parent is cancelled.
+// It returns how many invoices were queued.
+func sendOutbox(ctx context.Context, parent context.Context, drainTimeout time.Duration, outbox *fifo.Queue[Invoice], sqsClient queue.Queue, queueUrl string) int {
+	sendCtx, cancel := helpers.DrainContext(parent, drainTimeout)
+	defer cancel()
+
+	sent, batch := 0, 0
+	send := func(invoices []Invoice) {
+		batch++
+		sent += queueInvoices(sendCtx, invoices, batch, sqsClient, queueUrl)
+	}
+
+	for {
+		first, err := outbox.Dequeue(ctx)
+		if err != nil {
+			break
+		}
+		invoices := []Invoice{first}
+		for len(invoices) < broker.MaxBatchSize {
+			next, ok := outbox.TryDequeue()
+			if !ok {
+				break
+			}
+			invoices = append(invoices, next)
 		}
-		message, err := json.Marshal(newInvoice)
+		send(invoices)
+	}
+
+	rest := outbox.Drain()
+	for start := 0; start < len(rest); start += broker.MaxBatchSize {
+		send(rest[start:min(start+broker.MaxBatchSize, len(rest))])
+	}
+
+	return sent
+}
+
+func queueInvoices(ctx context.Context, invoices []Invoice, batch int, sqsClient queue.Queue, queueUrl string) int {
+	sent, err := SendInvoices(ctx, invoices, strconv.Itoa(batch), sqsClient, queueUrl)
+	if err != nil {
+		logger.Error("invoice batch not fully sent", "batch", batch, "err", err)
+	} else if sent == len(invoices) {
+		metrics.LastTick.SetToCurrentTime()
+	}
+
+	logger.Info("invoice batch queued", "batch", batch, "sent", sent, "size", len(invoices))
+	return sent
+}
+
+// runId tells the invoices queued by this process apart from the ones of
+// earlier runs, whose batch numbers also started at 1.
+var runId = newRunId()
+
+func newRunId() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
+// invoiceKey is the deduplication id and idempotency key of the invoice at
+// position in group. Slugs never contain ".", so the parts can't run into
+// each other, and the run id keeps keys from repeating across restarts.
+func invoiceKey(name string, group string, position int) string {
+	groupSlug, nameSlug := slug.Make(group), slug.Make(name)
+	return fmt.Sprintf("%s.%s.%d.%s", runId, groupSlug[:min(len(groupSlug), 32)], position, nameSlug[:min(len(nameSlug), 40)])
+}
+
+// SendInvoices queues invoices in message group group and returns how many
+// were accepted. The deduplication id of each invoice is made of the run,
+// the group and its position, so sending the same invoices under the same
+// group again from this process, inside the SQS deduplication window,
+// queues nothing new.
+func SendInvoices(ctx context.Context, invoices []Invoice, group string, sqsClient queue.Queue, queueUrl string) (int, error) {
+	messages := make([]queue.BatchMessage, 0, len(invoices))
+	for y, invoice := range invoices {
+		message, err := InvoiceMessage(invoice, group, invoiceKey(invoice.Name, group, y+1))
 		if err != nil {
-			helpers.LogError(logFile, err.Error())
+			return 0, err
 		}
-		g := new(string)
-		duplicationId := new(string)
-		*duplicationId = slug.Make(newInvoice.Name + strconv.Itoa(requestId) + strconv.Itoa(y))
-		*g = strconv.Itoa(requestId)
+		messages = append(messages, message)
+	}
 
-		sqsClient.SendMessage(ctx, queueUrl, message, g, duplicationId)
+	// resending a whole batch after a failed call is safe, the dedup ids
+	// drop the entries that already made it
+	var result queue.BatchResult
+	err := queue.Retry(ctx, 3, func() error {
+		var err error
+		result, err = sqsClient.SendMessageBatch(ctx, queueUrl, messages)
+		return err
+	})
+	if err != nil {
+		return len(result.Successful), err
 	}
+
+	return len(result.Successful), result.Err()
 }
 
-func requestCreation(invoice Invoice) {
-	fmt.Println(invoice)
+// InvoiceMessage wraps invoice in an envelope ready to be queued. key is
+// used both as the SQS deduplication id and as the API idempotency key.
+func InvoiceMessage(invoice Invoice, group string, key string) (queue.BatchMessage, error) {
+	body, err := json.Marshal(invoice)
+	if err != nil {
+		return queue.BatchMessage{}, err
+	}
+
+	envelope := queue.NewEnvelope(queue.TypeInvoiceCreate, invoiceVersion, body)
+	envelope.IdempotencyKey = key
+
+	return queue.BatchMessage{
+		Id:         key,
+		Body:       envelope.Body,
+		Attributes: envelope.Attributes(),
+		Group:      &group,
+		DupId:      &key,
+	}, nil
+}
+
+func requestCreation(ctx context.Context, client InvoiceClient, invoice Invoice, idempotencyKey string) (queue.CreatedInvoice, error) {
+	created, err := client.Submit(ctx, invoice, idempotencyKey)
+	if err != nil {
+		return queue.CreatedInvoice{}, err
+	}
+
+	logger.InfoContext(ctx, "invoice created", "invoice_id", created.Id, "name", created.Name, "amount", created.Amount, "fee", created.Fee)
+	return created, nil
 }
@@ -0,0 +1,131 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"test/starkbank/mocked/app/model"
+	"test/starkbank/money"
+	"test/starkbank/project/consumer"
+	"test/starkbank/project/ledger"
+	"test/starkbank/project/queue"
+	"test/starkbank/project/settings"
+	"time"
+)
+
+// transferVersion is the schema version of PaidInvoice as it is queued.
+const transferVersion = 1
+
+// StatusPayed is the invoice status that triggers a transfer.
+const StatusPayed = "PAYED"
+
+type (
+	// PaidInvoice is queued for every created invoice while transfers are
+	// on. Its handler transfers Amount minus Fee to the configured
+	// destination once the API shows the invoice PAYED, and fails until
+	// then so SQS redelivers it: the redeliveries poll the invoice.
+	PaidInvoice struct {
+		InvoiceId int64       `json:"invoice_id"`
+		Amount    money.Money `json:"amount"`
+		Fee       money.Money `json:"fee"`
+	}
+)
+
+// TransferKey is the idempotency key of the transfer for an invoice. There
+// is one per invoice, however many times it is reported paid.
+func TransferKey(invoiceId int64) string {
+	return "transfer-" + strconv.FormatInt(invoiceId, 10)
+}
+
+// PaidMessage wraps paid in an envelope ready to be queued. Messages for the
+// same invoice share a group and a deduplication id.
+func PaidMessage(paid PaidInvoice) (queue.BatchMessage, error) {
+	body, err := json.Marshal(paid)
+	if err != nil {
+		return queue.BatchMessage{}, err
+	}
+
+	key := TransferKey(paid.InvoiceId)
+	group := "invoice-" + strconv.FormatInt(paid.InvoiceId, 10)
+
+	envelope := queue.NewEnvelope(queue.TypeTransferCreate, transferVersion, body)
+	envelope.IdempotencyKey = key
+
+	return queue.BatchMessage{
+		Id:         key,
+		Body:       envelope.Body,
+		Attributes: envelope.Attributes(),
+		Group:      &group,
+		DupId:      &key,
+	}, nil
+}
+
+// transferHandler pays out a paid invoice and records the transfer in book.
+// The status and amounts are checked with the API first, which is the
+// source of truth: the invoice may have been queued before it was paid.
+// Invoices the ledger already shows as transferred are acked untouched, and
+// the API answers with the existing transfer for an invoice paid out before,
+// so a paid event delivered twice never pays twice.
+func transferHandler(client InvoiceClient, book *ledger.Ledger, dest settings.Transfer) consumer.EnvelopeHandler {
+	return func(ctx context.Context, envelope queue.Envelope) error {
+		var paid PaidInvoice
+		if err := json.Unmarshal(envelope.Body, &paid); err != nil {
+			return err
+		}
+
+		entry, ok, err := book.Get(paid.InvoiceId)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("invoice %d is not in the ledger", paid.InvoiceId)
+		}
+		if entry.Transferred() {
+			return nil
+		}
+
+		invoice, err := client.Get(ctx, paid.InvoiceId)
+		if err != nil {
+			return err
+		}
+		if invoice.Status != StatusPayed {
+			return fmt.Errorf("invoice %d is %s, waiting for it to be PAYED", paid.InvoiceId, invoice.Status)
+		}
+
+		amount := invoice.Amount.Sub(invoice.Fee)
+		if amount <= 0 {
+			logger.WarnContext(ctx, "paid amount doesn't cover the fee, nothing to transfer", "invoice_id", paid.InvoiceId, "amount", invoice.Amount, "fee", invoice.Fee)
+			return nil
+		}
+
+		transfer, err := client.Transfer(ctx, model.TransferRequest{
+			InvoiceId:     paid.InvoiceId,
+			Amount:        amount,
+			Name:          dest.Name,
+			TaxId:         dest.TaxId,
+			BankCode:      dest.BankCode,
+			BranchCode:    dest.BranchCode,
+			AccountNumber: dest.Account,
+		}, TransferKey(paid.InvoiceId))
+		if err != nil {
+			return err
+		}
+
+		transferredAt := transfer.CreatedAt
+		if transferredAt.IsZero() {
+			transferredAt = time.Now().UTC()
+		}
+		if _, err := book.Update(paid.InvoiceId, func(entry *ledger.Entry) {
+			entry.Status = invoice.Status
+			entry.TransferId = transfer.ID
+			entry.TransferAmount = transfer.Amount
+			entry.TransferredAt = transferredAt
+		}); err != nil {
+			return err
+		}
+
+		logger.InfoContext(ctx, "transfer made", "transfer_id", transfer.ID, "amount", transfer.Amount, "invoice_id", paid.InvoiceId)
+		return nil
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"test/starkbank/project/requests"
+	"test/starkbank/project/scheduler"
+)
+
+// runCmd queues invoices on SCHEDULE and consumes them until the schedule
+// is over or the process is stopped:
+//
+//	project run
+func runCmd(ctx context.Context, e *env, flags *flag.FlagSet, args []string) error {
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	schedule, err := scheduler.New(e.cfg.Schedule)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errConfig, err)
+	}
+	book, err := e.openLedger()
+	if err != nil {
+		return err
+	}
+	if err := e.setupQueue(ctx); err != nil {
+		return err
+	}
+	e.serveMetrics(ctx)
+
+	return requests.CreateInvoice(ctx, e.cfg, e.queueUrl, e.queue, schedule, book)
+}
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"test/starkbank/logging"
+	"time"
+)
+
+var logger = logging.For("scheduler")
+
+type (
+	// MissedPolicy decides what happens to ticks that passed while a run was
+	// still busy.
+	MissedPolicy string
+
+	Config struct {
+		Spec      string
+		Window    time.Duration
+		MaxRuns   int
+		BatchSize int
+		Missed    MissedPolicy
+	}
+
+	Run struct {
+		Number    int
+		Scheduled time.Time
+		Started   time.Time
+		BatchSize int
+	}
+
+	Scheduler struct {
+		config   Config
+		schedule Schedule
+	}
+)
+
+const (
+	// Skip drops every missed tick and waits for the next one in the future.
+	Skip MissedPolicy = "skip"
+	// CatchUp runs once for every missed tick, back to back.
+	CatchUp MissedPolicy = "catchup"
+)
+
+func DefaultConfig() Config {
+	return Config{
+		Spec:      "@every 3m",
+		Window:    24 * time.Minute,
+		BatchSize: 8,
+		Missed:    Skip,
+	}
+}
+
+func (c Config) Validate() error {
+	if _, err := Parse(c.Spec); err != nil {
+		return err
+	}
+	if c.Window < 0 {
+		return fmt.Errorf("schedule window can't be negative")
+	}
+	if c.MaxRuns < 0 {
+		return fmt.Errorf("schedule max runs can't be negative")
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("schedule batch size must be at least 1")
+	}
+	if c.Missed != Skip && c.Missed != CatchUp {
+		return fmt.Errorf("unknown missed tick policy %q, use %q or %q", c.Missed, Skip, CatchUp)
+	}
+
+	return nil
+}
+
+func New(config Config) (*Scheduler, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	schedule, _ := Parse(config.Spec)
+
+	return &Scheduler{config: config, schedule: schedule}, nil
+}
+
+func (s *Scheduler) Config() Config {
+	return s.config
+}
+
+// Run calls job on every tick until ctx is done, the run window closes or
+// MaxRuns is reached. Runs never overlap; ticks that pass while a job is busy
+// are handled according to the missed tick policy.
+func (s *Scheduler) Run(ctx context.Context, job func(ctx context.Context, run Run)) {
+	if s.config.Window > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Window)
+		defer cancel()
+	}
+
+	next := s.schedule.Next(time.Now())
+	for n := 1; s.config.MaxRuns == 0 || n <= s.config.MaxRuns; n++ {
+		if next.IsZero() {
+			logger.Info("schedule has no further activations")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Info("stopping the periodic task", "cause", context.Cause(ctx))
+			return
+		case <-timer.C:
+		}
+
+		run := Run{
+			Number:    n,
+			Scheduled: next,
+			Started:   time.Now(),
+			BatchSize: s.config.BatchSize,
+		}
+		logger.Info("scheduled run started", "run", run.Number, "scheduled", run.Scheduled.Format("15:04:05"), "started", run.Started.Format("15:04:05"), "batch_size", run.BatchSize)
+		job(ctx, run)
+
+		next = s.following(next)
+	}
+
+	logger.Info("reached the limit of runs", "max_runs", s.config.MaxRuns)
+}
+
+func (s *Scheduler) following(last time.Time) time.Time {
+	next := s.schedule.Next(last)
+	now := time.Now()
+	if !next.Before(now) {
+		return next
+	}
+
+	if s.config.Missed == CatchUp {
+		return next
+	}
+
+	skipped := 0
+	for !next.IsZero() && next.Before(now) {
+		next = s.schedule.Next(next)
+		skipped++
+	}
+	logger.Warn("skipped missed ticks", "skipped", skipped, "next", next.Format("15:04:05"))
+
+	return next
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Config)
+		wantErr bool
+	}{
+		{name: "default", change: func(*Config) {}},
+		{name: "bad spec", change: func(c *Config) { c.Spec = "soon" }, wantErr: true},
+		{name: "negative window", change: func(c *Config) { c.Window = -time.Second }, wantErr: true},
+		{name: "negative max runs", change: func(c *Config) { c.MaxRuns = -1 }, wantErr: true},
+		{name: "empty batch", change: func(c *Config) { c.BatchSize = 0 }, wantErr: true},
+		{name: "unknown policy", change: func(c *Config) { c.Missed = "later" }, wantErr: true},
+		{name: "catch up", change: func(c *Config) { c.Missed = CatchUp }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			tt.change(&config)
+			if err := config.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFollowing(t *testing.T) {
+	last := time.Now().Add(-5500 * time.Millisecond)
+
+	tests := []struct {
+		missed MissedPolicy
+		check  func(next time.Time) bool
+		want   string
+	}{
+		{CatchUp, func(next time.Time) bool { return next.Equal(last.Add(time.Second)) }, "the first missed tick"},
+		{Skip, func(next time.Time) bool { return next.Equal(last.Add(6 * time.Second)) }, "the first tick in the future"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.missed), func(t *testing.T) {
+			s := &Scheduler{config: Config{Missed: tt.missed}, schedule: Interval{Every: time.Second}}
+			if next := s.following(last); !tt.check(next) {
+				t.Fatalf("following() = %s after the last run, want %s", next.Sub(last), tt.want)
+			}
+		})
+	}
+}
+
+// TestRunMissed runs a job slower than its interval: catching up keeps
+// every tick, skipping drops the ones that passed while it was busy.
+func TestRunMissed(t *testing.T) {
+	const every = 20 * time.Millisecond
+
+	for _, missed := range []MissedPolicy{CatchUp, Skip} {
+		t.Run(string(missed), func(t *testing.T) {
+			s := &Scheduler{
+				config:   Config{MaxRuns: 4, BatchSize: 1, Missed: missed},
+				schedule: Interval{Every: every},
+			}
+
+			var scheduled []time.Time
+			s.Run(context.Background(), func(ctx context.Context, run Run) {
+				scheduled = append(scheduled, run.Scheduled)
+				time.Sleep(50 * time.Millisecond)
+			})
+
+			if len(scheduled) != 4 {
+				t.Fatalf("%d runs, want 4", len(scheduled))
+			}
+			for i := 1; i < len(scheduled); i++ {
+				gap := scheduled[i].Sub(scheduled[i-1])
+				if missed == CatchUp && gap != every {
+					t.Fatalf("run %d scheduled %s after the previous one, catching up keeps every tick", i+1, gap)
+				}
+				if missed == Skip && gap <= every {
+					t.Fatalf("run %d scheduled %s after the previous one, missed ticks should be skipped", i+1, gap)
+				}
+			}
+		})
+	}
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	s := &Scheduler{config: Config{BatchSize: 1, Missed: Skip}, schedule: Interval{Every: time.Hour}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, func(context.Context, Run) { t.Error("job ran before its tick") })
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run() didn't return once its context was done")
+	}
+}
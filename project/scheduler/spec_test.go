@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Schedule
+		wantErr bool
+	}{
+		{spec: "3h", want: Interval{Every: 3 * time.Hour}},
+		{spec: "@every 90s", want: Interval{Every: 90 * time.Second}},
+		{spec: "@hourly"},
+		{spec: "0 */3 * * *"},
+		{spec: "30 9 * * 1-5"},
+		{spec: "0 0 1,15 * 7"},
+		{spec: "", wantErr: true},
+		{spec: "500ms", wantErr: true},
+		{spec: "@every soon", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %v, want an error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			if tt.want != nil && got != tt.want {
+				t.Fatalf("Parse(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// 2026-10-17 is a saturday
+	tests := []struct {
+		spec  string
+		after string
+		want  string
+	}{
+		{"0 */3 * * *", "2026-10-17 10:30", "2026-10-17 12:00"},
+		{"0 */3 * * *", "2026-10-17 12:00", "2026-10-17 15:00"},
+		{"*/15 * * * *", "2026-10-17 10:59", "2026-10-17 11:00"},
+		{"@daily", "2026-10-17 00:00", "2026-10-18 00:00"},
+		{"@monthly", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"30 9 * * 1-5", "2026-10-17 08:00", "2026-10-19 09:30"},
+		// 7 is sunday as well as 0
+		{"0 12 * * 7", "2026-10-17 13:00", "2026-10-18 12:00"},
+		// a restricted day of month and day of week match when either does
+		{"0 0 20 * 1", "2026-10-17 00:00", "2026-10-19 00:00"},
+		// a field starting with * is unrestricted even with a step, so
+		// both have to match
+		{"0 0 */2 * 0", "2026-10-17 00:00", "2026-10-25 00:00"},
+		{"0 0 20 * */2", "2026-10-17 00:00", "2026-10-20 00:00"},
+		{"0 0 31 * *", "2026-11-01 00:00", "2026-12-31 00:00"},
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+" after "+tt.after, func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(at(tt.after)); !got.Equal(at(tt.want)) {
+				t.Fatalf("Next(%s) = %s, want %s", tt.after, got.Format("2006-01-02 15:04"), tt.want)
+			}
+		})
+	}
+}
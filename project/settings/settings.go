@@ -0,0 +1,169 @@
+// Package settings adds the sections only the project reads to the shared
+// config, so the mocked binaries don't link the packages behind them.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"test/starkbank/config"
+	"test/starkbank/project/consumer"
+	"test/starkbank/project/guard"
+	"test/starkbank/project/scheduler"
+	"test/starkbank/taxid"
+)
+
+type (
+	// Config is the shared config plus the invoice API guard, the schedule,
+	// the consumer, the transfer destination and the metrics endpoint.
+	Config struct {
+		config.Config
+
+		ApiGuard guard.Config
+		Schedule scheduler.Config
+		Consumer consumer.Config
+		Transfer Transfer
+
+		// MetricsAddr is where /metrics is served, empty turns it off.
+		MetricsAddr string
+
+		// LedgerPath is LEDGER_PATH as it was set, LedgerFile resolves it.
+		LedgerPath string
+		// ledgerOrigin is where LedgerPath was read from.
+		ledgerOrigin string
+	}
+
+	// Transfer is the account PAYED invoices are transferred to. Transfers
+	// are off while Account is empty.
+	Transfer struct {
+		Name       string
+		TaxId      string
+		BankCode   string
+		BranchCode string
+		Account    string
+	}
+)
+
+// Keys are the settings of the project sections, register them with
+// config.RegisterFlags.
+var Keys = []config.Key{
+	{Name: "API_RATE_LIMIT", Usage: "requests per second sent to the invoice API, 0 for no limit"},
+	{Name: "API_RATE_BURST", Usage: "requests sent to the invoice API at once before the rate limit applies"},
+	{Name: "API_BREAKER_FAILURES", Usage: "consecutive 5xx responses or timeouts that open the breaker, 0 disables it"},
+	{Name: "API_BREAKER_COOLDOWN", Usage: "how long the breaker stays open before probing the API"},
+	{Name: "API_BREAKER_PROBES", Usage: "probes that must succeed in a row to close the breaker"},
+	{Name: "SCHEDULE", Usage: "cron expression, @every duration or bare duration between runs"},
+	{Name: "SCHEDULE_WINDOW", Usage: "stop scheduling after this long, 0 runs until stopped"},
+	{Name: "SCHEDULE_MAX_RUNS", Usage: "stop after this many runs, 0 for no limit"},
+	{Name: "SCHEDULE_BATCH_SIZE", Usage: "invoices queued per run"},
+	{Name: "SCHEDULE_MISSED", Usage: "missed tick policy, skip or catchup"},
+	{Name: "CONSUMER_WORKERS", Usage: "handler goroutines"},
+	{Name: "CONSUMER_MAX_IN_FLIGHT", Usage: "messages handled at once"},
+	{Name: "CONSUMER_HEARTBEAT", Usage: "how often in-flight messages get their visibility extended, 0 disables it"},
+	{Name: "CONSUMER_VISIBILITY_TIMEOUT", Usage: "visibility added by every heartbeat"},
+	{Name: "SHUTDOWN_DRAIN_TIMEOUT", Usage: "time in-flight work gets to finish after SIGINT/SIGTERM"},
+	{Name: "TRANSFER_NAME", Usage: "name on the transfer destination account"},
+	{Name: "TRANSFER_TAX_ID", Usage: "CPF or CNPJ of the transfer destination"},
+	{Name: "TRANSFER_BANK_CODE", Usage: "bank code of the transfer destination"},
+	{Name: "TRANSFER_BRANCH_CODE", Usage: "branch code of the transfer destination"},
+	{Name: "TRANSFER_ACCOUNT", Usage: "account PAYED invoices are transferred to, empty turns transfers off"},
+	{Name: "METRICS_ADDR", Usage: "address of the Prometheus /metrics endpoint, empty turns it off"},
+	{Name: "LEDGER_PATH", Usage: "append-only record of created invoices"},
+}
+
+func Default() Config {
+	return Config{
+		Config:   config.Default(),
+		ApiGuard: guard.DefaultConfig(),
+		Schedule: scheduler.DefaultConfig(),
+		Consumer: consumer.DefaultConfig(),
+	}
+}
+
+// Load is config.Load with the project sections. flags must have been
+// registered with Keys.
+func Load(flags *config.Flags) (Config, error) {
+	cfg := Default()
+	shared, err := config.Load(flags, config.Section{Keys: Keys, Load: cfg.load})
+	cfg.Config = shared
+
+	return cfg, err
+}
+
+func (c *Config) load(v *config.Values) {
+	v.Float("API_RATE_LIMIT", &c.ApiGuard.Rate)
+	v.Int("API_RATE_BURST", &c.ApiGuard.Burst)
+	v.Int("API_BREAKER_FAILURES", &c.ApiGuard.Failures)
+	v.Duration("API_BREAKER_COOLDOWN", &c.ApiGuard.Cooldown)
+	v.Int("API_BREAKER_PROBES", &c.ApiGuard.Probes)
+
+	v.String("SCHEDULE", &c.Schedule.Spec)
+	v.Duration("SCHEDULE_WINDOW", &c.Schedule.Window)
+	v.Int("SCHEDULE_MAX_RUNS", &c.Schedule.MaxRuns)
+	v.Int("SCHEDULE_BATCH_SIZE", &c.Schedule.BatchSize)
+	if s, ok := v.Lookup("SCHEDULE_MISSED"); ok {
+		c.Schedule.Missed = scheduler.MissedPolicy(strings.ToLower(s))
+	}
+
+	v.Int("CONSUMER_WORKERS", &c.Consumer.Workers)
+	v.Int("CONSUMER_MAX_IN_FLIGHT", &c.Consumer.MaxInFlight)
+	v.Duration("CONSUMER_HEARTBEAT", &c.Consumer.Heartbeat)
+	v.Duration("CONSUMER_VISIBILITY_TIMEOUT", &c.Consumer.VisibilityTimeout)
+	v.Duration("SHUTDOWN_DRAIN_TIMEOUT", &c.Consumer.DrainTimeout)
+
+	v.String("TRANSFER_NAME", &c.Transfer.Name)
+	v.String("TRANSFER_TAX_ID", &c.Transfer.TaxId)
+	v.String("TRANSFER_BANK_CODE", &c.Transfer.BankCode)
+	v.String("TRANSFER_BRANCH_CODE", &c.Transfer.BranchCode)
+	v.String("TRANSFER_ACCOUNT", &c.Transfer.Account)
+
+	v.String("METRICS_ADDR", &c.MetricsAddr)
+	v.String("LEDGER_PATH", &c.LedgerPath)
+	c.ledgerOrigin = v.Origin("LEDGER_PATH")
+
+	v.Section("api guard", c.ApiGuard.Validate())
+	v.Section("schedule", c.Schedule.Validate())
+	v.Section("consumer", c.Consumer.Validate())
+	v.Section("transfer", c.Transfer.Validate())
+}
+
+// LedgerFile is where the ledger is kept. A relative LEDGER_PATH from the
+// env file is taken from that file's directory rather than the working
+// directory, and an unset one is data/ledger.jsonl next to the env file.
+// Paths from the environment or a flag are used as typed.
+func (c Config) LedgerFile() (string, error) {
+	dir := filepath.Dir(c.EnvFile)
+
+	switch {
+	case c.LedgerPath == "" && c.EnvFile == "":
+		return "", errors.New("LEDGER_PATH must be set when there is no .env to keep the ledger next to")
+	case c.LedgerPath == "":
+		return filepath.Join(dir, "data", "ledger.jsonl"), nil
+	case c.ledgerOrigin == c.EnvFile && !filepath.IsAbs(c.LedgerPath):
+		return filepath.Join(dir, c.LedgerPath), nil
+	}
+
+	return c.LedgerPath, nil
+}
+
+func (t Transfer) Enabled() bool {
+	return t.Account != ""
+}
+
+func (t Transfer) Validate() error {
+	if !t.Enabled() {
+		return nil
+	}
+	if t.Name == "" {
+		return fmt.Errorf("TRANSFER_NAME is required when TRANSFER_ACCOUNT is set")
+	}
+	if t.BankCode == "" || t.BranchCode == "" {
+		return fmt.Errorf("TRANSFER_BANK_CODE and TRANSFER_BRANCH_CODE are required when TRANSFER_ACCOUNT is set")
+	}
+	if !taxid.Valid(t.TaxId) {
+		return fmt.Errorf("TRANSFER_TAX_ID %q is not a valid CPF or CNPJ", t.TaxId)
+	}
+
+	return nil
+}
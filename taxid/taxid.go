@@ -0,0 +1,188 @@
+package taxid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type (
+	Kind string
+
+	// TaxId is a validated CPF or CNPJ. Value holds the normalized form:
+	// digits only for CPF, digits and upper case letters for CNPJ.
+	TaxId struct {
+		Kind  Kind
+		Value string
+	}
+)
+
+const (
+	CPF  Kind = "CPF"
+	CNPJ Kind = "CNPJ"
+
+	cpfSize  = 11
+	cnpjSize = 14
+)
+
+var (
+	ErrEmpty       = errors.New("tax id is empty")
+	ErrLength      = errors.New("tax id must have 11 (CPF) or 14 (CNPJ) characters")
+	ErrCharacters  = errors.New("tax id has invalid characters")
+	ErrRepeated    = errors.New("tax id can't be a single repeated character")
+	ErrCheckDigits = errors.New("tax id check digits don't match")
+)
+
+var (
+	cpfWeights  = []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2}
+	cnpjWeights = []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+)
+
+// Parse strips the usual punctuation ("." "-" "/" and spaces), then checks
+// the length, the characters and the check digits. CNPJs may use the
+// alphanumeric format, where the first 12 characters can be letters.
+func Parse(s string) (TaxId, error) {
+	value := strip(s)
+	if value == "" {
+		return TaxId{}, ErrEmpty
+	}
+
+	var kind Kind
+	switch len(value) {
+	case cpfSize:
+		kind = CPF
+	case cnpjSize:
+		kind = CNPJ
+	default:
+		return TaxId{}, fmt.Errorf("%w, got %d", ErrLength, len(value))
+	}
+
+	base, verifiers := value[:len(value)-2], value[len(value)-2:]
+	if !isDigits(verifiers) || (kind == CPF && !isDigits(base)) || (kind == CNPJ && !isAlphanumeric(base)) {
+		return TaxId{}, ErrCharacters
+	}
+	if strings.Count(value, value[:1]) == len(value) {
+		return TaxId{}, ErrRepeated
+	}
+
+	expected, err := CheckDigits(kind, base)
+	if err != nil {
+		return TaxId{}, err
+	}
+	if verifiers != expected {
+		return TaxId{}, ErrCheckDigits
+	}
+
+	return TaxId{Kind: kind, Value: value}, nil
+}
+
+// Normalize returns the validated tax id without punctuation.
+func Normalize(s string) (string, error) {
+	id, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	return id.Value, nil
+}
+
+// Format returns the validated tax id with the usual punctuation.
+func Format(s string) (string, error) {
+	id, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	return id.Formatted(), nil
+}
+
+func Valid(s string) bool {
+	_, err := Parse(s)
+	return err == nil
+}
+
+// CheckDigits computes the two modulo 11 verifiers for a CPF base (9 digits)
+// or a CNPJ base (12 characters). Each character is worth its ASCII code
+// minus 48, which keeps digits as they are and extends the rule to letters.
+func CheckDigits(kind Kind, base string) (string, error) {
+	var weights []int
+	switch kind {
+	case CPF:
+		weights = cpfWeights
+	case CNPJ:
+		weights = cnpjWeights
+	default:
+		return "", fmt.Errorf("unknown tax id kind %q", kind)
+	}
+	if len(base) != len(weights)-1 {
+		return "", fmt.Errorf("%s base must have %d characters", kind, len(weights)-1)
+	}
+
+	first := mod11(base, weights[1:])
+	second := mod11(base+string(rune('0'+first)), weights)
+
+	return string(rune('0'+first)) + string(rune('0'+second)), nil
+}
+
+func (t TaxId) String() string {
+	return t.Value
+}
+
+func (t TaxId) Formatted() string {
+	v := t.Value
+	switch t.Kind {
+	case CPF:
+		return v[:3] + "." + v[3:6] + "." + v[6:9] + "-" + v[9:]
+	case CNPJ:
+		return v[:2] + "." + v[2:5] + "." + v[5:8] + "/" + v[8:12] + "-" + v[12:]
+	}
+
+	return v
+}
+
+func mod11(base string, weights []int) int {
+	sum := 0
+	for i := 0; i < len(base); i++ {
+		sum += int(base[i]-'0') * weights[i]
+	}
+
+	rest := sum % 11
+	if rest < 2 {
+		return 0
+	}
+
+	return 11 - rest
+}
+
+func strip(s string) string {
+	var b strings.Builder
+	for _, c := range strings.ToUpper(s) {
+		switch c {
+		case '.', '-', '/', ' ', '\t':
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isAlphanumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'A' || s[i] > 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
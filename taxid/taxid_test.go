@@ -0,0 +1,92 @@
+package taxid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in        string
+		kind      Kind
+		value     string
+		formatted string
+		err       error
+	}{
+		{in: "529.982.247-25", kind: CPF, value: "52998224725", formatted: "529.982.247-25"},
+		{in: "52998224725", kind: CPF, value: "52998224725", formatted: "529.982.247-25"},
+		{in: " 529 982 247 25 ", kind: CPF, value: "52998224725", formatted: "529.982.247-25"},
+		{in: "11.222.333/0001-81", kind: CNPJ, value: "11222333000181", formatted: "11.222.333/0001-81"},
+		{in: "11222333000181", kind: CNPJ, value: "11222333000181", formatted: "11.222.333/0001-81"},
+		// alphanumeric CNPJ, letters are worth their ASCII code minus 48
+		{in: "12.ABC.345/01DE-35", kind: CNPJ, value: "12ABC34501DE35", formatted: "12.ABC.345/01DE-35"},
+		{in: "12.abc.345/01de-35", kind: CNPJ, value: "12ABC34501DE35", formatted: "12.ABC.345/01DE-35"},
+		{in: "", err: ErrEmpty},
+		{in: " .-/ ", err: ErrEmpty},
+		{in: "5299822472", err: ErrLength},
+		{in: "529982247250", err: ErrLength},
+		{in: "5299822472A", err: ErrCharacters},
+		{in: "12ABC34501DEAB", err: ErrCharacters},
+		{in: "111.111.111-11", err: ErrRepeated},
+		{in: "00000000000000", err: ErrRepeated},
+		{in: "529.982.247-26", err: ErrCheckDigits},
+		{in: "11.222.333/0001-80", err: ErrCheckDigits},
+		{in: "12.ABC.345/01DE-36", err: ErrCheckDigits},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+				}
+				if Valid(tt.in) {
+					t.Fatalf("Valid(%q) = true", tt.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.in, err)
+			}
+			if got.Kind != tt.kind || got.Value != tt.value {
+				t.Fatalf("Parse(%q) = %s %s, want %s %s", tt.in, got.Kind, got.Value, tt.kind, tt.value)
+			}
+			if formatted, _ := Format(tt.in); formatted != tt.formatted {
+				t.Fatalf("Format(%q) = %q, want %q", tt.in, formatted, tt.formatted)
+			}
+			if normalized, _ := Normalize(tt.in); normalized != tt.value {
+				t.Fatalf("Normalize(%q) = %q, want %q", tt.in, normalized, tt.value)
+			}
+		})
+	}
+}
+
+func TestCheckDigits(t *testing.T) {
+	tests := []struct {
+		kind    Kind
+		base    string
+		want    string
+		wantErr bool
+	}{
+		{kind: CPF, base: "529982247", want: "25"},
+		{kind: CPF, base: "111444777", want: "35"},
+		{kind: CNPJ, base: "112223330001", want: "81"},
+		{kind: CNPJ, base: "12ABC34501DE", want: "35"},
+		{kind: CPF, base: "52998224", wantErr: true},
+		{kind: CNPJ, base: "529982247", wantErr: true},
+		{kind: "RG", base: "529982247", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind)+" "+tt.base, func(t *testing.T) {
+			got, err := CheckDigits(tt.kind, tt.base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckDigits() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("CheckDigits() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}